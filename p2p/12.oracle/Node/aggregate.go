@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Aggregation methods used to combine the prices of several sources
+const (
+	AggregationMedian      = "median"
+	AggregationTrimmedMean = "trimmed-mean"
+)
+
+// Share of the lowest and highest prices dropped by the trimmed mean (on each side)
+const trimFraction = 0.2
+
+// Scale factor turning the MAD into a standard deviation estimate for normal data
+var madScale = big.NewRat(14826, 10000)
+
+// AggregatedPrice is the value a node submits for a feed and how it was built
+type AggregatedPrice struct {
+	// Exact aggregate of the source prices, rounded only by Feed.ToChain
+	Price *big.Rat
+
+	// Sources whose price made it into the value
+	Sources []string
+
+	// Sources dropped as outliers by the MAD filter
+	Rejected []string
+
+	// Sources that failed to answer
+	Failed []string
+}
+
+type sourcePrice struct {
+	source string
+	price  *big.Rat
+}
+
+// Query every source of a feed at the same time
+func fetchAllPrices(ctx context.Context, feed Feed, sources []PriceSource) ([]sourcePrice, map[string]error) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		prices []sourcePrice
+		errs   = make(map[string]error)
+	)
+
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source PriceSource) {
+			defer wg.Done()
+			price, err := source.FetchPrice(ctx, feed)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[source.Name()] = err
+				return
+			}
+			if price == nil || price.Sign() <= 0 {
+				errs[source.Name()] = fmt.Errorf("invalid price %v", price)
+				return
+			}
+			prices = append(prices, sourcePrice{source: source.Name(), price: price})
+		}(source)
+	}
+	wg.Wait()
+
+	// Keep a stable order so logs and trimmed means do not depend on timing
+	sort.Slice(prices, func(i, j int) bool {
+		if c := prices[i].price.Cmp(prices[j].price); c != 0 {
+			return c < 0
+		}
+		return prices[i].source < prices[j].source
+	})
+	return prices, errs
+}
+
+// Sort prices in ascending order
+func sortRats(values []*big.Rat) {
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+}
+
+// Median of a sorted slice, the mean of the two middle values for an even length
+func median(sorted []*big.Rat) *big.Rat {
+	n := len(sorted)
+	if n == 0 {
+		return new(big.Rat)
+	}
+	if n%2 == 1 {
+		return new(big.Rat).Set(sorted[n/2])
+	}
+	sum := new(big.Rat).Add(sorted[n/2-1], sorted[n/2])
+	return sum.Quo(sum, big.NewRat(2, 1))
+}
+
+// Split prices into kept values and outliers using the median absolute deviation
+// A price is an outlier when |price - median| > threshold * 1.4826 * MAD
+func filterOutliers(prices []sourcePrice, threshold float64) (kept, rejected []sourcePrice) {
+	thresholdRat := new(big.Rat)
+	if len(prices) < 3 || threshold <= 0 || thresholdRat.SetFloat64(threshold) == nil {
+		return prices, nil
+	}
+
+	values := make([]*big.Rat, len(prices))
+	for i, p := range prices {
+		values[i] = p.price
+	}
+	sortRats(values)
+	m := median(values)
+
+	deviations := make([]*big.Rat, len(values))
+	for i, v := range values {
+		deviations[i] = new(big.Rat).Sub(v, m)
+		deviations[i].Abs(deviations[i])
+	}
+	sortRats(deviations)
+	mad := median(deviations)
+
+	limit := new(big.Rat).Mul(thresholdRat, madScale)
+	limit.Mul(limit, mad)
+	for _, p := range prices {
+		// With a zero MAD most sources agree exactly, anything else is an outlier
+		deviation := new(big.Rat).Sub(p.price, m)
+		if deviation.Abs(deviation).Cmp(limit) > 0 {
+			rejected = append(rejected, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	return kept, rejected
+}
+
+// Mean of a sorted slice after dropping trimFraction of the values on each side
+func trimmedMean(sorted []*big.Rat) *big.Rat {
+	trim := int(float64(len(sorted)) * trimFraction)
+	kept := sorted[trim : len(sorted)-trim]
+
+	sum := new(big.Rat)
+	for _, v := range kept {
+		sum.Add(sum, v)
+	}
+	return sum.Quo(sum, big.NewRat(int64(len(kept)), 1))
+}
+
+// Combine source prices with the configured method
+func aggregate(prices []sourcePrice, method string) (*big.Rat, error) {
+	values := make([]*big.Rat, len(prices))
+	for i, p := range prices {
+		values[i] = p.price
+	}
+	sortRats(values)
+
+	switch method {
+	case "", AggregationMedian:
+		return median(values), nil
+	case AggregationTrimmedMean:
+		return trimmedMean(values), nil
+	}
+	return nil, fmt.Errorf("unknown aggregation method %q", method)
+}
+
+// Query each provider able to quote several feeds once for all the feeds using it
+// The per-feed fetches of the tick are then served from the shared fetcher cache
+func (n *OracleNode) prefetchPrices(ctx context.Context) {
+	type batch struct {
+		source *fetchedSource
+		feeds  []Feed
+	}
+	batches := make(map[string]*batch)
+	var order []string
+	for _, feed := range n.feeds {
+		for _, source := range n.sources[feed.Key] {
+			fetched, ok := source.(*fetchedSource)
+			if !ok || !fetched.Batchable() {
+				continue
+			}
+			b, ok := batches[fetched.cacheKey]
+			if !ok {
+				b = &batch{source: fetched}
+				batches[fetched.cacheKey] = b
+				order = append(order, fetched.cacheKey)
+			}
+			b.feeds = append(b.feeds, feed)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range order {
+		b := batches[key]
+		if len(b.feeds) < 2 {
+			continue // Nothing to save, the feed is fetched on its own
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.source.FetchPrices(ctx, b.feeds)
+			if err != nil {
+				// Feeds fall back to one request each
+				n.logger.Warn("Batch price request failed", "source", b.source.Name(),
+					"coins", strings.Join(feedKeys(b.feeds), ","), "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Fetch a feed from the given sources, drop outliers and aggregate what remains
+// Nothing is logged nor recorded, the result lists the failed and rejected sources
+func (n *OracleNode) quotePrice(ctx context.Context, feed Feed, sources []PriceSource) (*AggregatedPrice, map[string]error, error) {
+	prices, errs := fetchAllPrices(ctx, feed, sources)
+
+	result := &AggregatedPrice{}
+	for name := range errs {
+		result.Failed = append(result.Failed, name)
+	}
+	sort.Strings(result.Failed)
+
+	kept, rejected := filterOutliers(prices, n.config.OutlierThreshold)
+	for _, p := range rejected {
+		result.Rejected = append(result.Rejected, p.source)
+	}
+
+	minSources := n.config.MinSources
+	if minSources < 1 {
+		minSources = 1
+	}
+	if len(kept) < minSources {
+		return result, errs, fmt.Errorf("only %d of %d sources usable, need %d (failed: [%s], rejected: [%s])",
+			len(kept), len(sources), minSources, strings.Join(result.Failed, ", "), strings.Join(result.Rejected, ", "))
+	}
+
+	price, err := aggregate(kept, n.config.Aggregation)
+	if err != nil {
+		return result, errs, err
+	}
+	result.Price = price
+	for _, p := range kept {
+		result.Sources = append(result.Sources, p.source)
+	}
+	sort.Strings(result.Sources)
+	return result, errs, nil
+}
+
+// Aggregate the price of a feed from its configured sources for a submission
+func (n *OracleNode) aggregatePrice(ctx context.Context, feed Feed) (*AggregatedPrice, error) {
+	coin := feed.Key
+	sources := n.sources[coin]
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no price source configured")
+	}
+
+	result, errs, err := n.quotePrice(ctx, feed, sources)
+	for _, name := range result.Failed {
+		n.logger.Warn("Price source failed", "coin", coin, "source", name, "error", errs[name])
+	}
+	n.recordFetch(coin, len(sources)-len(errs), result.Failed)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	n.lastPrices[coin] = result
+	n.mu.Unlock()
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+)
+
+func sourcePrices(t *testing.T, prices map[string]string) []sourcePrice {
+	t.Helper()
+	var result []sourcePrice
+	for source, text := range prices {
+		result = append(result, sourcePrice{source: source, price: mustParseDecimal(t, text)})
+	}
+	return result
+}
+
+func TestAggregateExact(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		prices map[string]string
+		want   string
+	}{
+		{"odd median", AggregationMedian, map[string]string{"a": "3000.1", "b": "3000.3", "c": "3000.2"}, "3000.2"},
+		{"even median", AggregationMedian, map[string]string{"a": "0.1", "b": "0.2"}, "0.15"},
+		{"even median below feed precision", AggregationMedian, map[string]string{"a": "1.00000001", "b": "1.00000002"}, "1.000000015"},
+		{"trimmed mean", AggregationTrimmedMean, map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "100"}, "3"},
+		{"large values", AggregationMedian, map[string]string{"a": "9007199254740993", "b": "9007199254740995"}, "9007199254740994"},
+	}
+	for _, test := range tests {
+		got, err := aggregate(sourcePrices(t, test.prices), test.method)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if want := mustParseDecimal(t, test.want); got.Cmp(want) != 0 {
+			t.Errorf("%s: got %s, want %s", test.name, formatDecimal(got), test.want)
+		}
+	}
+}
+
+// Every node must reach the same integer whatever order the sources answered in
+func TestAggregateToChainIsDeterministic(t *testing.T) {
+	feed := Feed{Key: "ETH/USD", Decimals: 8}
+	orders := [][]string{
+		{"3000.000000005", "3000.000000015", "2999.99999999"},
+		{"2999.99999999", "3000.000000015", "3000.000000005"},
+		{"3000.000000015", "2999.99999999", "3000.000000005"},
+	}
+	var first string
+	for _, order := range orders {
+		var prices []sourcePrice
+		for i, text := range order {
+			prices = append(prices, sourcePrice{source: string(rune('a' + i)), price: mustParseDecimal(t, text)})
+		}
+		price, err := aggregate(prices, AggregationMedian)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := feed.ToChain(price).String()
+		if first == "" {
+			first = got
+		}
+		if got != first || got != "300000000001" {
+			t.Errorf("order %v gave %s, want 300000000001", order, got)
+		}
+	}
+}
+
+func TestFilterOutliersExact(t *testing.T) {
+	prices := sourcePrices(t, map[string]string{
+		"a": "3000.00", "b": "3000.01", "c": "3000.02", "d": "3100",
+	})
+	kept, rejected := filterOutliers(prices, 3)
+	if len(kept) != 3 || len(rejected) != 1 || rejected[0].source != "d" {
+		t.Errorf("kept %v, rejected %v, want d rejected", kept, rejected)
+	}
+
+	// Identical quotes have a zero MAD and must all be kept
+	same := sourcePrices(t, map[string]string{"a": "0.1", "b": "0.10", "c": "1e-1"})
+	if kept, rejected := filterOutliers(same, 3); len(kept) != 3 || len(rejected) != 0 {
+		t.Errorf("equal prices: kept %d, rejected %d", len(kept), len(rejected))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Time allowed to the RPC calls of an API request
+const apiTimeout = 10 * time.Second
+
+// Write a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Find a feed of the node by key (ex: "ethereum", "ETH/EUR") or by CoinGecko ID and quote currency
+// A key only matches when vs is empty or the quote of the feed, an empty vs means usd otherwise
+func (n *OracleNode) findFeed(coin, vs string) (Feed, bool) {
+	for _, feed := range n.feeds {
+		if strings.EqualFold(feed.Key, coin) && (vs == "" || strings.EqualFold(feed.Quote, vs)) {
+			return feed, true
+		}
+	}
+	if vs == "" {
+		vs = "usd"
+	}
+	for _, feed := range n.feeds {
+		if feed.Coin == coin && strings.EqualFold(feed.Quote, vs) {
+			return feed, true
+		}
+	}
+	return Feed{}, false
+}
+
+// Read the feed named by the coin and vs query parameters, see findFeed
+func (n *OracleNode) feedParam(w http.ResponseWriter, r *http.Request) (Feed, bool) {
+	coin := r.URL.Query().Get("coin")
+	if coin == "" {
+		http.Error(w, "Missing 'coin' query parameter", http.StatusBadRequest)
+		return Feed{}, false
+	}
+	feed, ok := n.findFeed(coin, r.URL.Query().Get("vs"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Feed %q not tracked by this node, tracked: %s", coin, strings.Join(feedKeys(n.feeds), ", ")),
+			http.StatusNotFound)
+	}
+	return feed, ok
+}
+
+// Record the outcome of a submission transaction in the history and for /submission
+func (n *OracleNode) recordSubmission(record HistoryRecord) {
+	record.Time = time.Now()
+	n.store.Record(n.logger, record)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.coinStatusLocked(record.Coin).Submission = &record
+}
+
+// Serve /price?coin=ethereum&vs=eur, the off-chain price of a feed of the node
+// aggregated from its sources. Untracked coins are refused: quoting them would spend
+// the API keys and rate limits the submissions depend on
+func (n *OracleNode) priceHandler(w http.ResponseWriter, r *http.Request) {
+	feed, ok := n.feedParam(w, r)
+	if !ok {
+		return
+	}
+
+	aggregated, _, err := n.quotePrice(r.Context(), feed, n.sources[feed.Key])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch price: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"coin":     r.URL.Query().Get("coin"),
+		"feed":     feed.Key,
+		"price":    json.Number(formatDecimal(aggregated.Price)),
+		"currency": strings.ToLower(feed.Quote),
+		"sources":  aggregated.Sources,
+		"rejected": aggregated.Rejected,
+		"failed":   aggregated.Failed,
+	})
+}
+
+// Serve /onchain?coin=, the finalized price of a feed and its current round
+func (n *OracleNode) onChainHandler(w http.ResponseWriter, r *http.Request) {
+	feed, ok := n.feedParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+	defer cancel()
+
+	state, err := n.onChainPrice(ctx, feed.Key)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read contract: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	response := map[string]interface{}{
+		"coin":            feed.Key,
+		"price":           json.Number(feed.Format(state.Price)),
+		"price_raw":       state.Price.String(),
+		"decimals":        feed.Decimals,
+		"round_id":        state.RoundID.String(),
+		"submissions":     state.Submissions.String(),
+		"submitted":       state.Submitted,
+		"last_updated_at": nil,
+		"block":           state.Block,
+	}
+	if !state.LastUpdatedAt.IsZero() {
+		response["last_updated_at"] = state.LastUpdatedAt.UTC()
+	}
+	writeJSON(w, response)
+}
+
+// Serve /submission?coin=, the last submission transaction of this node for a feed
+// Read back from the history after a restart, submission is null until the node sent one
+func (n *OracleNode) submissionHandler(w http.ResponseWriter, r *http.Request) {
+	feed, ok := n.feedParam(w, r)
+	if !ok {
+		return
+	}
+
+	n.mu.Lock()
+	var submission *HistoryRecord
+	if status, ok := n.coinStatus[feed.Key]; ok {
+		submission = status.Submission
+	}
+	n.mu.Unlock()
+	if submission == nil {
+		var err error
+		if submission, err = n.store.Last(feed.Key, RecordSubmission); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read history: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"coin":       feed.Key,
+		"node":       n.config.Name,
+		"address":    n.address.Hex(),
+		"submission": submission,
+	})
+}
+
+// Serve /nodes, the registered nodes and the quorum of the contract
+func (n *OracleNode) nodesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), apiTimeout)
+	defer cancel()
+
+	quorum, err := n.contract.GetQuorum(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read quorum: %v", err), http.StatusBadGateway)
+		return
+	}
+	nodes, err := registeredNodes(ctx, n.contract)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read nodes: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	registered := false
+	addresses := make([]string, len(nodes))
+	for i, node := range nodes {
+		addresses[i] = node.Hex()
+		registered = registered || node == n.address
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"contract":   n.contractAddress.Hex(),
+		"quorum":     quorum.String(),
+		"nodes":      addresses,
+		"node":       n.config.Name,
+		"address":    n.address.Hex(),
+		"registered": registered,
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindFeed(t *testing.T) {
+	ethEUR := Feed{Key: "ETH/EUR", Coin: "ethereum", Base: "ETH", Quote: "EUR", Decimals: defaultDecimals}
+	node := &OracleNode{feeds: []Feed{ethereumFeed, ethEUR}}
+
+	tests := []struct {
+		coin, vs string
+		want     string // Key of the feed found, empty when none
+	}{
+		{"ethereum", "", "ethereum"},
+		{"ethereum", "usd", "ethereum"},
+		{"ethereum", "eur", "ETH/EUR"},
+		{"ETH/EUR", "", "ETH/EUR"},
+		{"eth/eur", "eur", "ETH/EUR"},
+		{"ETH/EUR", "usd", ""},
+		{"ethereum", "gbp", ""},
+		{"bitcoin", "", ""},
+	}
+	for _, test := range tests {
+		feed, ok := node.findFeed(test.coin, test.vs)
+		if ok != (test.want != "") || feed.Key != test.want {
+			t.Errorf("findFeed(%q, %q) = %q, %t, want %q", test.coin, test.vs, feed.Key, ok, test.want)
+		}
+	}
+}
+
+func TestPriceRefusesUntrackedCoins(t *testing.T) {
+	node := &OracleNode{config: &Config{}, feeds: []Feed{ethereumFeed}}
+	recorder := httptest.NewRecorder()
+	node.priceHandler(recorder, httptest.NewRequest(http.MethodGet, "/price?coin=bitcoin", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("/price?coin=bitcoin = %d, want 404", recorder.Code)
+	}
+}
+
+func TestSubmissionReadFromHistory(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "node.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, record := range []HistoryRecord{
+		{Kind: RecordSubmission, Coin: "ethereum", TxHash: "0x01", Status: "success"},
+		{Kind: RecordSubmission, Coin: "ethereum", TxHash: "0x02", Status: "reverted"},
+		{Kind: RecordRound, Coin: "ethereum", RoundID: "7"},
+	} {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A restarted node has no submission in memory
+	node := &OracleNode{config: &Config{Name: "node-0"}, feeds: []Feed{ethereumFeed}, store: store}
+	recorder := httptest.NewRecorder()
+	node.submissionHandler(recorder, httptest.NewRequest(http.MethodGet, "/submission?coin=ethereum", nil))
+
+	var response struct {
+		Submission *HistoryRecord `json:"submission"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Submission == nil || response.Submission.TxHash != "0x02" {
+		t.Errorf("submission = %+v, want the last one stored, 0x02", response.Submission)
+	}
+}
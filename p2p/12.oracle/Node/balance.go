@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Levels of the balance of a node account
+const (
+	BalanceOK       = "ok"       // Above warn_balance_eth
+	BalanceWarn     = "warn"     // Below warn_balance_eth, the account should be topped up
+	BalanceCritical = "critical" // Below min_balance_eth, submissions are paused
+)
+
+// Value of a balance level in the oracle_wallet_balance_level gauge
+var balanceLevelValues = map[string]float64{BalanceOK: 0, BalanceWarn: 1, BalanceCritical: 2}
+
+// Time allowed to a webhook to accept an alert
+var webhookHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// BalanceStatus is the balance of a node account at its last poll
+type BalanceStatus struct {
+	Balance   *big.Int  // Nil until a poll succeeds
+	Level     string    // Empty until a poll succeeds
+	CheckedAt time.Time // Last successful poll
+	Err       error     // Error of the last poll, nil when it succeeded
+}
+
+// BalanceAlert is the JSON body posted to the webhook when the level changes
+// text makes it readable as is by Slack-compatible webhooks
+type BalanceAlert struct {
+	Text          string    `json:"text"`
+	Node          string    `json:"node"`
+	Address       string    `json:"address"`
+	Level         string    `json:"level"`
+	PreviousLevel string    `json:"previous_level,omitempty"`
+	BalanceEth    float64   `json:"balance_eth"`
+	WarnEth       float64   `json:"warn_balance_eth"`
+	CriticalEth   float64   `json:"min_balance_eth"`
+	Time          time.Time `json:"time"`
+}
+
+// BalanceMonitor polls the balance of a node account and raises alerts when it
+// crosses the warn or critical threshold. Below the critical one the node
+// pauses its submissions until the account is topped up
+type BalanceMonitor struct {
+	client   ChainBackend
+	address  common.Address
+	node     string
+	warn     *big.Int // In wei, zero disables the warning
+	critical *big.Int // In wei
+	interval time.Duration
+	webhook  string // Alerts are only logged when empty
+	logger   *slog.Logger
+	metrics  *Metrics
+
+	mu     sync.Mutex
+	status BalanceStatus
+	alerts sync.WaitGroup // Alerts being sent to the webhook
+}
+
+// NewBalanceMonitor watches the account of a node with the thresholds of its config
+func NewBalanceMonitor(client ChainBackend, address common.Address, config *Config, logger *slog.Logger, metrics *Metrics) *BalanceMonitor {
+	return &BalanceMonitor{
+		client:   client,
+		address:  address,
+		node:     config.Name,
+		warn:     ethToWei(config.WarnBalanceEth),
+		critical: ethToWei(config.MinBalanceEth),
+		interval: time.Duration(config.BalancePollInterval) * time.Second,
+		webhook:  config.BalanceWebhookURL,
+		logger:   logger,
+		metrics:  metrics,
+	}
+}
+
+// Run polls the balance every interval until ctx is done, then waits for the
+// alerts still being sent
+func (m *BalanceMonitor) Run(ctx context.Context) {
+	defer m.alerts.Wait()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Poll(ctx); err != nil && ctx.Err() == nil {
+				m.logger.Warn("Failed to get balance", "error", err)
+			}
+		}
+	}
+}
+
+// Poll reads the balance, updates the metrics and alerts when the level changed
+func (m *BalanceMonitor) Poll(ctx context.Context) error {
+	balance, err := m.client.BalanceAt(ctx, m.address, nil)
+	if err != nil {
+		m.mu.Lock()
+		m.status.Err = err
+		m.mu.Unlock()
+		return err
+	}
+	level := m.level(balance)
+
+	m.mu.Lock()
+	previous := m.status.Level
+	m.status = BalanceStatus{Balance: balance, Level: level, CheckedAt: time.Now()}
+	m.mu.Unlock()
+
+	m.metrics.SetBalance(balance)
+	m.metrics.balanceLevel.Set(balanceLevelValues[level])
+
+	// The first poll only alerts on a low balance, later ones on every change
+	if level == previous || (previous == "" && level == BalanceOK) {
+		return nil
+	}
+	alert := m.newAlert(balance, level, previous)
+	switch level {
+	case BalanceCritical:
+		m.logger.Error("Balance below the critical level, submissions paused", "balance_eth", alert.BalanceEth, "min_balance_eth", alert.CriticalEth)
+	case BalanceWarn:
+		m.logger.Warn("Balance low, top up the node account", "balance_eth", alert.BalanceEth, "warn_balance_eth", alert.WarnEth)
+	default:
+		m.logger.Info("Balance back above the warning level", "balance_eth", alert.BalanceEth)
+	}
+	if m.webhook != "" {
+		// Delivered in the background, a slow webhook must not hold a submission
+		m.alerts.Add(1)
+		go func() {
+			defer m.alerts.Done()
+			m.sendAlert(alert)
+		}()
+	}
+	return nil
+}
+
+// Level of a balance against the thresholds
+func (m *BalanceMonitor) level(balance *big.Int) string {
+	switch {
+	case balance.Cmp(m.critical) < 0:
+		return BalanceCritical
+	case balance.Cmp(m.warn) < 0:
+		return BalanceWarn
+	}
+	return BalanceOK
+}
+
+// Status returns the balance at the last poll
+func (m *BalanceMonitor) Status() BalanceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Paused tells whether submissions are paused for a critical balance
+// An unknown balance does not pause them, the transactions will tell
+func (m *BalanceMonitor) Paused() bool {
+	return m.Status().Level == BalanceCritical
+}
+
+func (m *BalanceMonitor) newAlert(balance *big.Int, level, previous string) BalanceAlert {
+	alert := BalanceAlert{
+		Node:          m.node,
+		Address:       m.address.Hex(),
+		Level:         level,
+		PreviousLevel: previous,
+		BalanceEth:    weiToEth(balance),
+		WarnEth:       weiToEth(m.warn),
+		CriticalEth:   weiToEth(m.critical),
+		Time:          time.Now().UTC(),
+	}
+	switch level {
+	case BalanceCritical:
+		alert.Text = fmt.Sprintf("Oracle node %s (%s) balance is %.6f ETH, below the critical level of %g ETH: submissions are paused",
+			alert.Node, alert.Address, alert.BalanceEth, alert.CriticalEth)
+	case BalanceWarn:
+		alert.Text = fmt.Sprintf("Oracle node %s (%s) balance is %.6f ETH, below the warning level of %g ETH",
+			alert.Node, alert.Address, alert.BalanceEth, alert.WarnEth)
+	default:
+		alert.Text = fmt.Sprintf("Oracle node %s (%s) balance is back to %.6f ETH", alert.Node, alert.Address, alert.BalanceEth)
+	}
+	return alert
+}
+
+// Post an alert to the webhook, failures are logged and not retried
+func (m *BalanceMonitor) sendAlert(alert BalanceAlert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		m.logger.Error("Failed to encode balance alert", "error", err)
+		return
+	}
+	resp, err := webhookHTTPClient.Post(m.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		m.logger.Warn("Failed to send balance alert", "level", alert.Level, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		m.logger.Warn("Balance alert rejected by the webhook", "level", alert.Level, "status", resp.StatusCode)
+	}
+}
+
+// Convert ETH to wei, for thresholds
+func ethToWei(eth float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(eth), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
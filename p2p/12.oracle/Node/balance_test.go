@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Alerts received by an httptest webhook
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []BalanceAlert
+}
+
+func newAlertWebhook(t *testing.T) (*alertRecorder, string) {
+	recorder := &alertRecorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert BalanceAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recorder.mu.Lock()
+		recorder.alerts = append(recorder.alerts, alert)
+		recorder.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return recorder, server.URL
+}
+
+// Levels of the alerts received so far
+func (r *alertRecorder) Levels() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var levels []string
+	for _, alert := range r.alerts {
+		levels = append(levels, alert.Level)
+	}
+	return levels
+}
+
+func TestSubmissionsPausedOnCriticalBalance(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1) // Node accounts hold 1 ETH
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	alerts, webhook := newAlertWebhook(t)
+
+	cfg := chain.nodeConfig(0, coingecko)
+	cfg.MinBalanceEth = 2
+	cfg.WarnBalanceEth = 5
+	cfg.BalanceWebhookURL = webhook
+	node := chain.newNode(t, cfg)
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first poll of the node found the balance critical
+	eventually(t, 5*time.Second, func() bool { return len(alerts.Levels()) == 1 }, "no alert for the critical balance")
+	if levels := alerts.Levels(); levels[0] != BalanceCritical {
+		t.Fatalf("alerts = %v, want [critical]", levels)
+	}
+	if check := node.checkBalance(); check.Status != CheckFail {
+		t.Errorf("balance check = %+v, want fail", check)
+	}
+	nonce := node.txManager.Nonce()
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("SubmitPrice: %v", err)
+	}
+	if node.txManager.Nonce() != nonce {
+		t.Fatal("a paused node sent a submission")
+	}
+	// The cycle ran and says why nothing was sent
+	details := node.checkSubmissions().Details.(map[string]interface{})["ethereum"].(map[string]interface{})
+	if details["last_result"] != SkipLowBalance {
+		t.Errorf("submissions check = %v, want the last cycle skipped for %s", details, SkipLowBalance)
+	}
+
+	// Topped up above the critical level but below the warning: submissions resume
+	node.balance.critical = ethToWei(0.5)
+	if err := node.balance.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, 5*time.Second, func() bool { return len(alerts.Levels()) == 2 }, "no alert for the warning level")
+	if levels := alerts.Levels(); levels[1] != BalanceWarn {
+		t.Fatalf("alerts = %v, want [critical warn]", levels)
+	}
+	if check := node.checkBalance(); check.Status != CheckWarn {
+		t.Errorf("balance check = %+v, want warn", check)
+	}
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("SubmitPrice: %v", err)
+	}
+	if node.txManager.Nonce() == nonce {
+		t.Fatal("no submission once the balance is above the critical level")
+	}
+
+	// Polls at an unchanged level do not alert again
+	if err := node.balance.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if levels := alerts.Levels(); len(levels) != 2 {
+		t.Fatalf("alerts = %v, want no new alert", levels)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ChainBackend is the Ethereum node the oracle talks to: contract calls and
+// transactions, receipts, balances and logs. *ethclient.Client implements it,
+// and so does the simulated backend of the tests
+type ChainBackend interface {
+	bind.ContractBackend
+	ethereum.BlockNumberReader
+	ethereum.ChainIDReader
+	ethereum.ChainStateReader
+	ethereum.TransactionReader
+}
+
+// Kinds of chain errors, errors returned by the transaction manager and the
+// node match them with errors.Is
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrNonceTooLow       = errors.New("nonce too low")
+	ErrUnderpriced       = errors.New("transaction underpriced")
+	ErrReverted          = errors.New("execution reverted")
+	ErrRPCDown           = errors.New("RPC unavailable")
+)
+
+// Messages of the txpool and state transition errors of geth, anvil and other
+// clients; over JSON-RPC only their text is left
+var errorMessages = []struct {
+	kind     error
+	messages []string
+}{
+	{ErrNonceTooLow, []string{"nonce too low", "nonce has already been used"}},
+	{ErrInsufficientFunds, []string{"insufficient funds"}},
+	{ErrUnderpriced, []string{"underpriced", "fee cap less than block base fee", "max fee per gas less than block base fee"}},
+}
+
+// ChainError is an error of the chain classified under one of the Err* kinds
+type ChainError struct {
+	Kind error
+	Err  error
+}
+
+func (e *ChainError) Error() string { return e.Err.Error() }
+
+func (e *ChainError) Unwrap() []error { return []error{e.Kind, e.Err} }
+
+// RevertError is a call or transaction rejected by the contract, with the
+// reason decoded from its Error(string) revert data
+type RevertError struct {
+	Reason string // Empty when the contract gave none or it could not be read
+	Data   []byte // Raw revert data
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return ErrReverted.Error()
+	}
+	return ErrReverted.Error() + ": " + e.Reason
+}
+
+func (e *RevertError) Is(target error) bool { return target == ErrReverted }
+
+// Kind of a classified error for logs and metrics, "other" when it has none
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		return "insufficient_funds"
+	case errors.Is(err, ErrNonceTooLow):
+		return "nonce_too_low"
+	case errors.Is(err, ErrUnderpriced):
+		return "underpriced"
+	case errors.Is(err, ErrReverted):
+		return "reverted"
+	case errors.Is(err, ErrRPCDown):
+		return "rpc_down"
+	}
+	return "other"
+}
+
+// classifyError sorts the error of an RPC call into one of the Err* kinds
+// Cancellations and errors of no known kind are returned unchanged
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	for _, kind := range []error{ErrInsufficientFunds, ErrNonceTooLow, ErrUnderpriced, ErrReverted, ErrRPCDown} {
+		if errors.Is(err, kind) {
+			return err
+		}
+	}
+
+	if revert := revertError(err); revert != nil {
+		return revert
+	}
+	message := strings.ToLower(err.Error())
+	for _, kind := range errorMessages {
+		for _, text := range kind.messages {
+			if strings.Contains(message, text) {
+				return &ChainError{Kind: kind.kind, Err: err}
+			}
+		}
+	}
+	if unreachable(err) {
+		return &ChainError{Kind: ErrRPCDown, Err: err}
+	}
+	return err
+}
+
+// Revert of a failed eth_call or eth_estimateGas, nil for any other error
+// The reason is decoded from the revert data, or taken from the message when
+// the node sends none
+func revertError(err error) *RevertError {
+	message := err.Error()
+	if !strings.Contains(message, ErrReverted.Error()) {
+		return nil
+	}
+	revert := &RevertError{}
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if text, ok := dataErr.ErrorData().(string); ok {
+			revert.Data, _ = hexutil.Decode(text)
+		}
+	}
+	if reason, unpackErr := abi.UnpackRevert(revert.Data); unpackErr == nil {
+		revert.Reason = reason
+	} else if _, reason, ok := strings.Cut(message, ErrReverted.Error()+": "); ok {
+		revert.Reason = reason
+	}
+	return revert
+}
+
+// Whether an error means the RPC endpoint could not be reached or is failing
+func unreachable(err error) bool {
+	var netErr net.Error
+	var httpErr rpc.HTTPError
+	switch {
+	case errors.As(err, &netErr), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, rpc.ErrClientQuit):
+		return true
+	case errors.As(err, &httpErr):
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error // nil when the error must be returned unchanged
+	}{
+		{errors.New("nonce too low: address 0xf39F, tx: 3 state: 4"), ErrNonceTooLow},
+		{errors.New("insufficient funds for gas * price + value: balance 0, tx cost 1"), ErrInsufficientFunds},
+		{errors.New("replacement transaction underpriced"), ErrUnderpriced},
+		{errors.New("max fee per gas less than block base fee: address 0xf39F, maxFeePerGas: 1, baseFee: 7"), ErrUnderpriced},
+		{errors.New("execution reverted: Not a node"), ErrReverted},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrRPCDown},
+		{rpc.HTTPError{StatusCode: 502, Status: "502 Bad Gateway"}, ErrRPCDown},
+		{rpc.ErrClientQuit, ErrRPCDown},
+		{rpc.HTTPError{StatusCode: 401, Status: "401 Unauthorized"}, nil},
+		{context.Canceled, nil},
+		{errors.New("invalid sender"), nil},
+	}
+	for _, test := range tests {
+		classified := classifyError(test.err)
+		if test.kind == nil {
+			if errorKind(classified) != "other" || classified.Error() != test.err.Error() {
+				t.Errorf("%v classified as %v, want it unchanged", test.err, errorKind(classified))
+			}
+			continue
+		}
+		// The kind survives the wrapping of the callers, and so does the original error
+		wrapped := fmt.Errorf("failed to send transaction: %w", classified)
+		if !errors.Is(wrapped, test.kind) {
+			t.Errorf("%v: errors.Is(%v) = false", test.err, test.kind)
+		}
+		if test.kind != ErrReverted && errors.Unwrap(wrapped).Error() != test.err.Error() {
+			t.Errorf("%v: the original error is lost", test.err)
+		}
+	}
+
+	if revert := classifyError(errors.New("execution reverted: Not a node")).(*RevertError); revert.Reason != "Not a node" {
+		t.Errorf("reason = %q, want Not a node", revert.Reason)
+	}
+}
+
+func TestRevertReasonOfMinedTransaction(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1)
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	node := chain.newNode(t, chain.nodeConfig(0, coingecko))
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node estimates gas first so it never sends a failing call itself, send one by hand
+	data, err := node.contractABI.Pack("submitPrice", "ethereum", big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignNewTx(chain.keys[0], types.LatestSignerForChainID(big.NewInt(simulatedChainID)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(simulatedChainID),
+		Nonce:     node.txManager.Nonce(),
+		To:        &chain.address,
+		Gas:       200000,
+		GasFeeCap: big.NewInt(100e9),
+		GasTipCap: big.NewInt(1e9),
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.client.SendTransaction(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	var receipt *types.Receipt
+	eventually(t, 10*time.Second, func() bool {
+		receipt, err = chain.client.TransactionReceipt(ctx, tx.Hash())
+		return err == nil
+	}, "transaction %s never mined", tx.Hash().Hex())
+	if receipt.Status != types.ReceiptStatusFailed {
+		t.Fatalf("second submission in the round mined with status %d", receipt.Status)
+	}
+
+	revert := node.txManager.RevertError(ctx, tx, receipt)
+	if revert.Reason != "Already submitted for this round" {
+		t.Errorf("reason = %q, want Already submitted for this round", revert.Reason)
+	}
+	if !errors.Is(fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), revert), ErrReverted) {
+		t.Errorf("%v does not match ErrReverted", revert)
+	}
+}
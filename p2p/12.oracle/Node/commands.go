@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Commands of the node binary, "run" is used when none is given
+var commands = map[string]string{
+	"run":        "run the oracle nodes (default)",
+	"register":   "register the nodes in the oracle contract",
+	"deregister": "remove the nodes from the oracle contract",
+	"status":     "show the contract membership, quorum and node balances",
+	"rotate-key": "register a new key for a node, then deregister its current one",
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [-config config.yaml] [-node name]\n\nCommands:\n", os.Args[0])
+	for _, name := range []string{"run", "register", "deregister", "status", "rotate-key"} {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name])
+	}
+	fmt.Fprintf(os.Stderr, "\nrotate-key also takes the new key: -new-private-key, -new-keystore with\n"+
+		"-new-passphrase-file, or -new-external-signer with -new-signer-address\n")
+}
+
+// KeyFlags select the new key of rotate-key, same options as the config file
+type KeyFlags struct {
+	PrivateKey     string
+	Keystore       string
+	PassphraseFile string
+	ExternalSigner string
+	SignerAddress  string
+}
+
+// Register adds the -new-* flags to a flag set
+func (k *KeyFlags) Register(flags *flag.FlagSet) {
+	flags.StringVar(&k.PrivateKey, "new-private-key", "", "new raw private key (hex)")
+	flags.StringVar(&k.Keystore, "new-keystore", "", "new go-ethereum keystore file")
+	flags.StringVar(&k.PassphraseFile, "new-passphrase-file", "", "file holding the passphrase of -new-keystore")
+	flags.StringVar(&k.ExternalSigner, "new-external-signer", "", "remote signer URL of the new key")
+	flags.StringVar(&k.SignerAddress, "new-signer-address", "", "address of the new key in the remote signer")
+}
+
+// Copy of a node config signing with the new key
+func (k KeyFlags) apply(cfg *Config) *Config {
+	newCfg := cfg.clone()
+	newCfg.PrivateKey = strings.TrimPrefix(k.PrivateKey, "0x")
+	newCfg.Keystore = k.Keystore
+	newCfg.PassphraseFile = k.PassphraseFile
+	newCfg.ExternalSigner = k.ExternalSigner
+	newCfg.SignerAddress = k.SignerAddress
+	return &newCfg
+}
+
+// Connect each node in turn and run action on it
+func forEachNode(ctx context.Context, configs []*Config, action func(*OracleNode) error) error {
+	var errs []error
+	for _, cfg := range configs {
+		node, err := NewOracleNode(ctx, cfg, cfg.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+			continue
+		}
+		if err := action(node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Name, err))
+		}
+		node.Close()
+	}
+	return errors.Join(errs...)
+}
+
+// Read the registered nodes, the contract has no length getter so read until out of bounds
+func registeredNodes(ctx context.Context, contract *Oracle) ([]common.Address, error) {
+	var nodes []common.Address
+	for i := int64(0); ; i++ {
+		node, err := contract.Nodes(&bind.CallOpts{Context: ctx}, big.NewInt(i))
+		if err != nil {
+			// Reading past the end reverts, any other failure would truncate the list
+			if errors.Is(classifyError(err), ErrReverted) {
+				return nodes, nil
+			}
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// Print the oracle membership and the state of the configured nodes
+func printStatus(ctx context.Context, configs []*Config) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	first := true
+	return forEachNode(ctx, configs, func(n *OracleNode) error {
+		opts := &bind.CallOpts{Context: ctx}
+
+		// Contract-wide state, printed once
+		if first {
+			first = false
+			owner, err := n.contract.Owner(opts)
+			if err != nil {
+				return fmt.Errorf("failed to read owner: %w", err)
+			}
+			quorum, err := n.contract.GetQuorum(opts)
+			if err != nil {
+				return fmt.Errorf("failed to read quorum: %w", err)
+			}
+			nodes, err := registeredNodes(ctx, n.contract)
+			if err != nil {
+				return fmt.Errorf("failed to read nodes: %w", err)
+			}
+
+			fmt.Fprintf(w, "Contract:\t%s\n", n.contractAddress.Hex())
+			fmt.Fprintf(w, "Owner:\t%s\n", owner.Hex())
+			fmt.Fprintf(w, "Quorum:\t%s of %d nodes\n\n", quorum, len(nodes))
+			fmt.Fprintf(w, "REGISTERED NODE\tBALANCE (ETH)\n")
+			for _, node := range nodes {
+				balance, err := n.client.BalanceAt(ctx, node, nil)
+				if err != nil {
+					return fmt.Errorf("failed to read balance of %s: %w", node.Hex(), err)
+				}
+				fmt.Fprintf(w, "%s\t%.6f\n", node.Hex(), weiToEth(balance))
+			}
+			fmt.Fprintf(w, "\nCONFIGURED NODE\tADDRESS\tREGISTERED\tBALANCE (ETH)\n")
+		}
+
+		isNode, err := n.contract.IsNode(opts, n.address)
+		if err != nil {
+			return fmt.Errorf("failed to read isNode: %w", err)
+		}
+		balance, err := n.client.BalanceAt(ctx, n.address, nil)
+		if err != nil {
+			return fmt.Errorf("failed to read balance: %w", err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%.6f\n", n.config.Name, n.address.Hex(), isNode, weiToEth(balance))
+		return nil
+	})
+}
+
+// Register the new key of a node before removing the old one, so the oracle
+// never has one node less than expected
+func rotateKey(ctx context.Context, cfg *Config, newKey KeyFlags) error {
+	newCfg := newKey.apply(cfg)
+	if err := validateConfigs([]*Config{newCfg}); err != nil {
+		return fmt.Errorf("invalid new key: %w", err)
+	}
+
+	oldNode, err := NewOracleNode(ctx, cfg, cfg.ID)
+	if err != nil {
+		return fmt.Errorf("current key: %w", err)
+	}
+	defer oldNode.Close()
+
+	newNode, err := NewOracleNode(ctx, newCfg, cfg.ID)
+	if err != nil {
+		return fmt.Errorf("new key: %w", err)
+	}
+	defer newNode.Close()
+
+	if newNode.address == oldNode.address {
+		return fmt.Errorf("new key has the same address %s as the current one", oldNode.address.Hex())
+	}
+
+	if err := newNode.EnsureRegistered(ctx); err != nil {
+		return fmt.Errorf("failed to register new key %s: %w", newNode.address.Hex(), err)
+	}
+	if err := oldNode.Deregister(ctx); err != nil {
+		return fmt.Errorf("new key %s registered but failed to deregister old key %s: %w",
+			newNode.address.Hex(), oldNode.address.Hex(), err)
+	}
+
+	fmt.Printf("Key of %s rotated: %s -> %s\n", cfg.Name, oldNode.address.Hex(), newNode.address.Hex())
+	fmt.Printf("Update the key of %s in the configuration file before restarting it\n", cfg.Name)
+	return nil
+}
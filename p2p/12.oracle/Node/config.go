@@ -1,59 +1,487 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is the configuration of one oracle node
 type Config struct {
+	// Name of the node in logs and for the -node flag (defaults to node-<index>)
+	Name string `yaml:"name"`
+
+	// Position of the node in the file, used as node ID in logs
+	ID int `yaml:"-"`
+
 	// Ethereum RPC URL (ex: http://localhost:8545 or Infura/Alchemy)
-	RPCURL string
+	RPCURL string `yaml:"rpc_url"`
+
+	// Fallback RPC URLs, used in order when rpc_url fails its health checks
+	RPCURLs []string `yaml:"rpc_urls"`
+
+	// Websocket RPC URL used to subscribe to events (ex: ws://localhost:8545)
+	// When empty, events are polled unless an RPC URL is itself a websocket URL
+	WSURL string `yaml:"ws_url"`
+
+	// Fallback websocket URLs, subscribed to in order when ws_url drops
+	WSURLs []string `yaml:"ws_urls"`
+
+	// Retries of an RPC call failing with a transient error, with exponential backoff
+	RPCRetries int `yaml:"rpc_retries"`
+
+	// Interval in seconds between two health checks of the RPC endpoints
+	RPCHealthCheckInterval int `yaml:"rpc_health_check_interval"`
+
+	// Interval in seconds between two polls of PriceUpdated events without websocket
+	EventPollInterval int `yaml:"event_poll_interval"`
 
 	// Oracle contract address
-	ContractAddress string
+	ContractAddress string `yaml:"contract_address"`
 
 	// Node private key (without 0x prefix)
-	PrivateKey string
+	PrivateKey string `yaml:"private_key"`
+
+	// go-ethereum keystore JSON file, decrypted with the content of PassphraseFile
+	Keystore       string `yaml:"keystore"`
+	PassphraseFile string `yaml:"passphrase_file"`
+
+	// Remote signer URL (ex: Clef at http://localhost:8550) signing for SignerAddress
+	ExternalSigner string `yaml:"external_signer"`
+	SignerAddress  string `yaml:"signer_address"`
+
+	// JSON-RPC method of the remote signer: eth_signTransaction (default) or account_signTransaction for Clef
+	ExternalSignerMethod string `yaml:"external_signer_method"`
+
+	// CoinGecko coin IDs to track in USD with 8 decimals, stored on-chain under the coin ID
+	// Defaults to ethereum when neither coins nor feeds are set
+	Coins []string `yaml:"coins"`
+
+	// Pair feeds (ex: ETH/EUR) stored on-chain under the pair, with their own decimals
+	Feeds []FeedConfig `yaml:"feeds"`
+
+	// Interval in seconds between two checks of the off-chain price
+	SubmissionInterval int `yaml:"submission_interval"`
+
+	// Submit when the off-chain price deviates from the on-chain one by this many basis points
+	DeviationThresholdBps int `yaml:"deviation_threshold_bps"`
+
+	// Submit when the on-chain price is older than this many seconds, whatever the deviation
+	HeartbeatInterval int `yaml:"heartbeat_interval"`
+
+	// Extra gas on top of EstimateGas, in percent
+	GasLimitMarginPercent uint64 `yaml:"gas_limit_margin_percent"`
+
+	// Max fee per gas a node may pay, in gwei (0 means no limit)
+	MaxFeeCapGwei int64 `yaml:"max_fee_cap_gwei"`
+
+	// Blocks a transaction may stay pending before being replaced with higher fees (0 disables)
+	StuckTxBlocks uint64 `yaml:"stuck_tx_blocks"`
+
+	// Fee increase of a replacement transaction, in percent (at least 10)
+	FeeBumpPercent uint64 `yaml:"fee_bump_percent"`
 
-	// CoinGecko coin IDs to track
-	Coins []string
+	// Seconds given to in-flight submissions and the HTTP server to finish on shutdown
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
 
-	// Submission interval in seconds
-	SubmissionInterval int
+	// Critical balance in ETH: below it submissions pause and readiness fails
+	MinBalanceEth float64 `yaml:"min_balance_eth"`
 
-	// HTTP server port
-	HTTPPort string
+	// Warning balance in ETH, above min_balance_eth (0 disables the warning)
+	WarnBalanceEth float64 `yaml:"warn_balance_eth"`
+
+	// Interval in seconds between two reads of the node balance
+	BalancePollInterval int `yaml:"balance_poll_interval"`
+
+	// URL receiving a JSON POST when the balance crosses a threshold (optional)
+	BalanceWebhookURL string `yaml:"balance_webhook_url"`
+
+	// Readiness fails when the chain head is older than this many seconds (0 disables)
+	MaxHeadAge int `yaml:"max_head_age"`
+
+	// Health checks fail when a coin had no successful cycle for this many submission intervals
+	StaleIntervals int `yaml:"stale_intervals"`
+
+	// Directory of the history database, stored as <data_dir>/<name>.db
+	DataDir string `yaml:"data_dir"`
+
+	// HTTP server address (ex: ":8080")
+	HTTPPort string `yaml:"http_port"`
 
 	// CoinGecko API Key
-	CoingeckoApiKey string
+	CoingeckoApiKey string `yaml:"coingecko_api_key"`
+
+	// Price sources to query for each feed, by coin ID or pair
+	// Sources of a node replace the top-level ones, which only apply to the feeds it tracks
+	Sources map[string][]SourceConfig `yaml:"sources"`
+
+	// How source prices are combined: "median" or "trimmed-mean"
+	Aggregation string `yaml:"aggregation"`
+
+	// MAD multiplier above which a source price is dropped as an outlier (0 disables the filter)
+	OutlierThreshold float64 `yaml:"outlier_threshold"`
+
+	// Minimum number of sources that must agree before submitting
+	MinSources int `yaml:"min_sources"`
+
+	// Minimum level of the logs: debug, info, warn or error
+	LogLevel string `yaml:"log_level"`
+
+	// Log output: "text" (key=value pairs) or "json" (one object per line)
+	LogFormat string `yaml:"log_format"`
+}
+
+// SourceConfig selects and configures one price provider
+type SourceConfig struct {
+	// Provider: coingecko, binance, kraken, coinbase or json
+	Type string `yaml:"type"`
+
+	// Name of a json source in logs, metrics and the API, json:<host of url> when empty
+	Name string `yaml:"name"`
+
+	// Market symbol on the provider (ex: ETHUSDT), derived from the coin when empty
+	Symbol string `yaml:"symbol"`
+
+	// Request URL for the json source, {coin} and {symbol} are replaced
+	// For coingecko and kraken, the API base URL (ex: a caching proxy), the public API when empty
+	URL string `yaml:"url"`
+
+	// Dot-separated path to the price in the json response (ex: data.amount)
+	Path string `yaml:"path"`
+
+	// API key sent to the provider
+	APIKey string `yaml:"api_key"`
+
+	// Requests per second allowed to this provider and API key, shared by every node
+	// of the process (0 uses the provider default)
+	RateLimit float64 `yaml:"rate_limit"`
 }
 
-func LoadConfig() *Config {
-	rpcURL := os.Getenv("RPC_URL")
-	if rpcURL == "" {
-		rpcURL = "http://localhost:8545" // Default to local Anvil/Hardhat
+// SourcesFor returns the price sources configured for a feed, CoinGecko by default
+func (c *Config) SourcesFor(key string) []SourceConfig {
+	if sources, ok := c.Sources[key]; ok && len(sources) > 0 {
+		return sources
 	}
-	contractAddr := os.Getenv("CONTRACT_ADDRESS")
-	if contractAddr == "" {
-		contractAddr = "0x5FbDB2315678afecb367f032d93F642f64180aa3" // Default Anvil first deployment
+	return []SourceConfig{{Type: "coingecko"}}
+}
+
+// RPCEndpoints returns rpc_url followed by the fallback rpc_urls, without duplicates
+func (c *Config) RPCEndpoints() []string {
+	return endpointList(c.RPCURL, c.RPCURLs)
+}
+
+// WSEndpoints returns ws_url followed by the fallback ws_urls, without duplicates
+func (c *Config) WSEndpoints() []string {
+	return endpointList(c.WSURL, c.WSURLs)
+}
+
+func endpointList(primary string, fallbacks []string) []string {
+	var urls []string
+	for _, endpoint := range append([]string{primary}, fallbacks...) {
+		if endpoint != "" && !slices.Contains(urls, endpoint) {
+			urls = append(urls, endpoint)
+		}
+	}
+	return urls
+}
+
+// Coin tracked by a node that declares neither coins nor feeds
+const defaultCoin = "ethereum"
+
+// Built-in values of every setting the file does not define
+func defaultConfig() Config {
+	return Config{
+		RPCURL:                 "http://localhost:8545",                      // Default to local Anvil/Hardhat
+		ContractAddress:        "0x5FbDB2315678afecb367f032d93F642f64180aa3", // Default Anvil first deployment
+		SubmissionInterval:     20,
+		EventPollInterval:      5,
+		RPCRetries:             3,
+		RPCHealthCheckInterval: 10,
+		DeviationThresholdBps:  50, // 0.5%
+		HeartbeatInterval:      3600,
+		GasLimitMarginPercent:  20,
+		MaxFeeCapGwei:          200,
+		StuckTxBlocks:          3,
+		FeeBumpPercent:         20,
+		ShutdownTimeout:        30,
+		DataDir:                "data",
+		MinBalanceEth:          0.01,
+		WarnBalanceEth:         0.05,
+		BalancePollInterval:    60,
+		MaxHeadAge:             120,
+		StaleIntervals:         5,
+		Aggregation:            AggregationMedian,
+		OutlierThreshold:       3,
+		MinSources:             1,
+		LogLevel:               "info",
+		LogFormat:              LogFormatText,
+	}
+}
+
+// Copy a config so nodes never share the slices and maps of the defaults
+func (c Config) clone() Config {
+	c.Coins = append([]string(nil), c.Coins...)
+	c.RPCURLs = append([]string(nil), c.RPCURLs...)
+	c.WSURLs = append([]string(nil), c.WSURLs...)
+	c.Feeds = append([]FeedConfig(nil), c.Feeds...)
+	sources := make(map[string][]SourceConfig, len(c.Sources))
+	for coin, list := range c.Sources {
+		sources[coin] = append([]SourceConfig(nil), list...)
+	}
+	c.Sources = sources
+	return c
+}
+
+// configFile is the layout of the YAML file: top-level settings are defaults
+// shared by every node, each entry of nodes overrides them
+type configFile struct {
+	Config `yaml:",inline"`
+	Nodes  []yaml.Node `yaml:"nodes"`
+}
+
+// Replace ${VAR} and ${VAR:-default} with environment variables
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if key, fallback, ok := strings.Cut(name, ":-"); ok {
+			if value := os.Getenv(key); value != "" {
+				return value
+			}
+			return fallback
+		}
+		return os.Getenv(name)
+	})
+}
+
+// Decode YAML rejecting unknown keys, so typos do not silently fall back to defaults
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(out)
+}
+
+// LoadConfig reads the node configurations from a YAML file and validates them
+func LoadConfig(path string) ([]*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := configFile{Config: defaultConfig()}
+	if err := decodeStrict([]byte(expandEnv(string(data))), &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(file.Nodes) == 0 {
+		return nil, fmt.Errorf("%s: no node defined under \"nodes\"", path)
 	}
 
-	privateKey := os.Getenv("PRIVATE_KEY")
-	if privateKey == "" {
-		// Default Anvil test key (DO NOT USE IN PRODUCTION)
-		privateKey = "ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80"
+	var configs []*Config
+	for i := range file.Nodes {
+		cfg := file.Config.clone()
+		cfg.Name = ""
+		inherited := cfg.Sources
+		cfg.Sources = nil // Decoded into a new map, not merged with the inherited one
+
+		raw, err := yaml.Marshal(&file.Nodes[i])
+		if err != nil {
+			return nil, fmt.Errorf("node #%d: %w", i, err)
+		}
+		if err := decodeStrict(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("node #%d (line %d): %w", i, file.Nodes[i].Line, err)
+		}
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("node-%d", i)
+		}
+		// A node pricing only pairs does not also get the default coin
+		if len(cfg.Coins) == 0 && len(cfg.Feeds) == 0 {
+			cfg.Coins = []string{defaultCoin}
+		}
+		if cfg.Sources == nil {
+			cfg.Sources = inheritedSources(inherited, &cfg)
+		}
+		cfg.ID = i
+		cfg.PrivateKey = strings.TrimPrefix(cfg.PrivateKey, "0x")
+		configs = append(configs, &cfg)
 	}
 
-	httpPort := os.Getenv("HTTP_PORT")
-	if httpPort == "" {
-		httpPort = ":8080"
+	if err := validateConfigs(configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// Top-level sources of the feeds a node tracks, the others are meant for other nodes
+func inheritedSources(sources map[string][]SourceConfig, cfg *Config) map[string][]SourceConfig {
+	feeds, err := cfg.ParseFeeds()
+	if err != nil {
+		return sources // Reported by validateConfigs
+	}
+	keys := feedKeys(feeds)
+	for key := range sources {
+		if !slices.Contains(keys, key) {
+			delete(sources, key)
+		}
 	}
+	return sources
+}
+
+// Check every node and report all problems at once
+func validateConfigs(configs []*Config) error {
+	var errs []error
+	names := make(map[string]bool)
+	ports := make(map[string]string)
+
+	for i, cfg := range configs {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("node %q (#%d): %s", cfg.Name, i, fmt.Sprintf(format, args...)))
+		}
+
+		if names[cfg.Name] {
+			fail("duplicate node name")
+		}
+		names[cfg.Name] = true
+
+		if cfg.DataDir == "" {
+			fail("data_dir is required")
+		}
+		if cfg.RPCURL == "" {
+			fail("rpc_url is required")
+		}
+		if cfg.WSURL == "" && len(cfg.WSURLs) > 0 {
+			fail("ws_urls are fallbacks of ws_url, which is required with them")
+		}
+		if cfg.RPCRetries < 0 {
+			fail("rpc_retries cannot be negative")
+		}
+		if cfg.RPCHealthCheckInterval <= 0 {
+			fail("rpc_health_check_interval must be positive")
+		}
+		if !common.IsHexAddress(cfg.ContractAddress) {
+			fail("contract_address %q is not a valid address", cfg.ContractAddress)
+		}
 
-	return &Config{
-		RPCURL:          rpcURL,
-		ContractAddress: contractAddr,
-		PrivateKey:      privateKey,
-		Coins:           []string{"ethereum"},
-		SubmissionInterval: 20,
-		HTTPPort:        httpPort,
+		keySources := 0
+		for _, source := range []string{cfg.PrivateKey, cfg.Keystore, cfg.ExternalSigner} {
+			if source != "" {
+				keySources++
+			}
+		}
+		switch {
+		case keySources != 1:
+			fail("exactly one of private_key, keystore or external_signer is required")
+		case cfg.PrivateKey != "":
+			if _, err := crypto.HexToECDSA(cfg.PrivateKey); err != nil {
+				fail("invalid private_key: %v", err)
+			}
+		case cfg.Keystore != "":
+			if cfg.PassphraseFile == "" {
+				fail("keystore requires a passphrase_file")
+			}
+		case cfg.ExternalSigner != "":
+			if !common.IsHexAddress(cfg.SignerAddress) {
+				fail("external_signer requires a valid signer_address")
+			}
+		}
+
+		if _, _, err := net.SplitHostPort(cfg.HTTPPort); err != nil {
+			fail("invalid http_port %q (expected \":8080\" or \"host:8080\")", cfg.HTTPPort)
+		} else if other, ok := ports[cfg.HTTPPort]; ok {
+			fail("http_port %s already used by node %q", cfg.HTTPPort, other)
+		} else {
+			ports[cfg.HTTPPort] = cfg.Name
+		}
+
+		feeds, err := cfg.ParseFeeds()
+		if err != nil {
+			fail("%v", err)
+		} else if len(feeds) == 0 {
+			fail("at least one coin or feed is required")
+		}
+		if cfg.SubmissionInterval <= 0 {
+			fail("submission_interval must be positive")
+		}
+		if cfg.EventPollInterval <= 0 {
+			fail("event_poll_interval must be positive")
+		}
+		if cfg.DeviationThresholdBps < 0 || cfg.HeartbeatInterval < 0 {
+			fail("deviation_threshold_bps and heartbeat_interval cannot be negative")
+		}
+		if cfg.FeeBumpPercent < minReplacementBumpPercent {
+			fail("fee_bump_percent must be at least %d", minReplacementBumpPercent)
+		}
+		if cfg.ShutdownTimeout <= 0 {
+			fail("shutdown_timeout must be positive")
+		}
+		if cfg.MinBalanceEth < 0 || cfg.MaxHeadAge < 0 {
+			fail("min_balance_eth and max_head_age cannot be negative")
+		}
+		if cfg.WarnBalanceEth != 0 && cfg.WarnBalanceEth < cfg.MinBalanceEth {
+			fail("warn_balance_eth must be above min_balance_eth, or 0 to disable the warning")
+		}
+		if cfg.BalancePollInterval <= 0 {
+			fail("balance_poll_interval must be positive")
+		}
+		if cfg.BalanceWebhookURL != "" {
+			if u, err := url.Parse(cfg.BalanceWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail("balance_webhook_url must be an http or https URL")
+			}
+		}
+		if cfg.StaleIntervals < 1 {
+			fail("stale_intervals must be at least 1")
+		}
+		if cfg.MaxFeeCapGwei < 0 {
+			fail("max_fee_cap_gwei cannot be negative")
+		}
+
+		if cfg.Aggregation != AggregationMedian && cfg.Aggregation != AggregationTrimmedMean {
+			fail("aggregation must be %q or %q", AggregationMedian, AggregationTrimmedMean)
+		}
+		if cfg.OutlierThreshold < 0 {
+			fail("outlier_threshold cannot be negative")
+		}
+		if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+			fail("%v", err)
+		}
+		if format := strings.ToLower(cfg.LogFormat); format != LogFormatText && format != LogFormatJSON {
+			fail("log_format must be %q or %q", LogFormatText, LogFormatJSON)
+		}
+
+		keys := feedKeys(feeds)
+		for key := range cfg.Sources {
+			if !slices.Contains(keys, key) {
+				fail("sources defined for %q which is not in coins or feeds", key)
+			}
+		}
+		for _, coin := range keys {
+			sources := cfg.SourcesFor(coin)
+			sourceNames := make(map[string]bool)
+			for _, source := range sources {
+				priceSource, err := NewPriceSource(source)
+				if err != nil {
+					fail("%s: %v", coin, err)
+				} else if name := priceSource.Name(); sourceNames[name] {
+					// Their prices and errors would be reported as one source
+					fail("%s: two sources named %s, set a distinct name on json sources", coin, name)
+				} else {
+					sourceNames[name] = true
+				}
+				if source.RateLimit < 0 {
+					fail("%s: rate_limit of %s cannot be negative", coin, source.Type)
+				}
+			}
+			if cfg.MinSources > len(sources) {
+				fail("min_sources is %d but %s only has %d sources", cfg.MinSources, coin, len(sources))
+			}
+		}
 	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Node of the test config files, its own settings follow
+const testNode = "  - http_port: \":8080\"\n    private_key: ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80\n"
+
+// Write a config file and load it
+func loadTestConfigs(t *testing.T, content string) []*Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return configs
+}
+
+// Write a config file with a single node and load it
+func loadTestConfig(t *testing.T, settings string) *Config {
+	t.Helper()
+	return loadTestConfigs(t, settings+"nodes:\n"+testNode)[0]
+}
+
+func TestLoadConfigDefaultCoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		want     []string // Feed keys of the node
+	}{
+		{"nothing", "", []string{"ethereum"}},
+		{"coins", "coins: [bitcoin]\n", []string{"bitcoin"}},
+		{"feeds only", "feeds:\n  - pair: ETH/EUR\n", []string{"ETH/EUR"}},
+		{"coins and feeds", "coins: [bitcoin]\nfeeds:\n  - pair: ETH/EUR\n", []string{"bitcoin", "ETH/EUR"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			feeds, err := loadTestConfig(t, test.settings).ParseFeeds()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keys := feedKeys(feeds); !reflect.DeepEqual(keys, test.want) {
+				t.Errorf("feeds = %v, want %v", keys, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigNodeSources(t *testing.T) {
+	configs := loadTestConfigs(t, `coins: [ethereum]
+sources:
+  ethereum:
+    - type: coingecko
+    - type: binance
+nodes:
+`+testNode+`    name: inherits
+`+strings.Replace(testNode, "8080", "8081", 1)+`    name: bitcoin
+    coins: [bitcoin]
+`+strings.Replace(testNode, "8080", "8082", 1)+`    name: feeds
+    feeds:
+      - pair: ETH/EUR
+`+strings.Replace(testNode, "8080", "8083", 1)+`    name: own
+    sources:
+      ethereum:
+        - type: kraken
+`)
+
+	sourceTypes := func(cfg *Config, key string) []string {
+		var types []string
+		for _, source := range cfg.SourcesFor(key) {
+			types = append(types, source.Type)
+		}
+		return types
+	}
+	tests := []struct {
+		node int
+		key  string
+		want []string
+	}{
+		{0, "ethereum", []string{"coingecko", "binance"}},
+		{1, "bitcoin", []string{"coingecko"}},
+		{2, "ETH/EUR", []string{"coingecko"}},
+		{3, "ethereum", []string{"kraken"}}, // Replaced, not merged
+	}
+	for _, test := range tests {
+		cfg := configs[test.node]
+		if types := sourceTypes(cfg, test.key); !reflect.DeepEqual(types, test.want) {
+			t.Errorf("%s: sources of %s = %v, want %v", cfg.Name, test.key, types, test.want)
+		}
+	}
+	if _, ok := configs[1].Sources["ethereum"]; ok {
+		t.Error("bitcoin node inherited the sources of ethereum")
+	}
+
+	// Sources a node declares itself must be for one of its feeds
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "coins: [bitcoin]\nnodes:\n" + testNode + "    sources:\n      ethereum:\n        - type: kraken\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `sources defined for "ethereum"`) {
+		t.Errorf("LoadConfig = %v, want an error about the sources of ethereum", err)
+	}
+}
+
+func TestJSONSourceNames(t *testing.T) {
+	sources := "coins: [ethereum]\nsources:\n  ethereum:\n" +
+		"    - type: json\n      url: https://example.com/a/{coin}\n" +
+		"    - type: json\n      url: https://example.com/b/{coin}\n%s" +
+		"    - type: json\n      url: https://other.example.com/{coin}\n"
+
+	// Sources are named after the host of their URL, the same one twice is ambiguous
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(strings.Replace(sources, "%s", "", 1)+"nodes:\n"+testNode), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "two sources named json:example.com") {
+		t.Errorf("LoadConfig = %v, want an error about json:example.com", err)
+	}
+
+	cfg := loadTestConfig(t, strings.Replace(sources, "%s", "      name: example-b\n", 1))
+	var names []string
+	for _, source := range cfg.SourcesFor("ethereum") {
+		priceSource, err := NewPriceSource(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, priceSource.Name())
+	}
+	if want := []string{"json:example.com", "example-b", "json:other.example.com"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Foundry tests of the reference contract, the specification the stand-in follows
+const referenceTests = "../utils/tests/*.t.sol"
+
+var (
+	asmDispatch     = regexp.MustCompile(`PUSH4 0x([0-9a-f]{8}) EQ @\w+ JUMPI\s*; (\S+)`)
+	asmTopic        = regexp.MustCompile(`PUSH32 0x([0-9a-f]{64})`)
+	solExpectRevert = regexp.MustCompile(`vm\.expectRevert\("([^"]*)"\)`)
+	solQuorum       = regexp.MustCompile(`assertEq\(oracle\.getQuorum\(\), (\d+), "Quorum with (\d+) nodes?`)
+	solEmit         = regexp.MustCompile(`emit Oracle\.PriceUpdated\("(\w+)", (\d+), (\d+)\)`)
+)
+
+// Read the reference Foundry tests as one text
+func readReferenceTests(t *testing.T) string {
+	t.Helper()
+	paths, err := filepath.Glob(referenceTests)
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no reference tests in %s", referenceTests)
+	}
+	var text strings.Builder
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text.Write(source)
+	}
+	return text.String()
+}
+
+// The dispatch table and event topic of the stand-in are those of Oracle.abi.json,
+// which the bindings are generated from
+func TestStandInMatchesABI(t *testing.T) {
+	abiJSON, err := os.Open("Oracle.abi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer abiJSON.Close()
+	reference, err := abi.JSON(abiJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings, err := OracleMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, method := range reference.Methods {
+		if bound, ok := bindings.Methods[name]; !ok || bound.Sig != method.Sig {
+			t.Errorf("bindings lack %s, regenerate them from Oracle.abi.json", method.Sig)
+		}
+	}
+	if len(bindings.Methods) != len(reference.Methods) {
+		t.Errorf("bindings have %d methods, Oracle.abi.json %d", len(bindings.Methods), len(reference.Methods))
+	}
+
+	source, err := os.ReadFile("testdata/Oracle.easm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dispatched []string
+	for _, match := range asmDispatch.FindAllStringSubmatch(string(source), -1) {
+		selector, signature := match[1], match[2]
+		method, err := reference.MethodById(common.FromHex(selector))
+		if err != nil {
+			t.Errorf("selector 0x%s (%s) is not in Oracle.abi.json", selector, signature)
+			continue
+		}
+		if method.Sig != signature {
+			t.Errorf("selector 0x%s is %s, commented as %s", selector, method.Sig, signature)
+		}
+		dispatched = append(dispatched, method.Name)
+	}
+	for name := range reference.Methods {
+		if !slices.Contains(dispatched, name) {
+			t.Errorf("%s is not implemented by the stand-in", reference.Methods[name].Sig)
+		}
+	}
+
+	topics := asmTopic.FindAllStringSubmatch(string(source), -1)
+	event := reference.Events["PriceUpdated"]
+	if len(topics) != 1 || common.HexToHash(topics[0][1]) != event.ID {
+		t.Errorf("stand-in topics = %v, want only PriceUpdated %s", topics, event.ID.Hex())
+	}
+}
+
+// The stand-in reverts with the messages, computes the quorum and emits the
+// event the reference tests expect, all read through the generated bindings
+func TestStandInMatchesReferenceTests(t *testing.T) {
+	ctx := testContext(t)
+	spec := readReferenceTests(t)
+	chain := newTestChain(t, 4)
+
+	// Send a transaction from the i-th node, returning the revert reason if any
+	send := func(i int, call func(*bind.TransactOpts) (*types.Transaction, error)) string {
+		t.Helper()
+		opts, err := bind.NewKeyedTransactorWithChainID(chain.keys[i], big.NewInt(simulatedChainID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Context = ctx
+		tx, err := call(opts)
+		if err != nil {
+			var revert *RevertError
+			if !errors.As(classifyError(err), &revert) {
+				t.Fatalf("node %d: %v", i, err)
+			}
+			return revert.Reason
+		}
+		if receipt, err := bind.WaitMined(ctx, chain.client, tx); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Fatalf("node %d: transaction failed: %v", i, err)
+		}
+		return ""
+	}
+	addNode := func(opts *bind.TransactOpts) (*types.Transaction, error) { return chain.contract.AddNode(opts) }
+	removeNode := func(opts *bind.TransactOpts) (*types.Transaction, error) { return chain.contract.RemoveNode(opts) }
+	submit := func(coin string, price int64) func(*bind.TransactOpts) (*types.Transaction, error) {
+		return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return chain.contract.SubmitPrice(opts, coin, big.NewInt(price))
+		}
+	}
+
+	quorums := make(map[int]int64)
+	for _, match := range solQuorum.FindAllStringSubmatch(spec, -1) {
+		nodes, _ := strconv.Atoi(match[2])
+		quorums[nodes], _ = strconv.ParseInt(match[1], 10, 64)
+	}
+	checkQuorum := func(nodes int) {
+		t.Helper()
+		want, ok := quorums[nodes]
+		if !ok {
+			return
+		}
+		delete(quorums, nodes)
+		if quorum, err := chain.contract.GetQuorum(&bind.CallOpts{Context: ctx}); err != nil || quorum.Int64() != want {
+			t.Errorf("quorum with %d nodes = %v, %v, want %d", nodes, quorum, err, want)
+		}
+	}
+
+	var reasons []string
+	expectRevert := func(reason string) {
+		t.Helper()
+		if reason == "" {
+			t.Fatal("transaction succeeded, want a revert")
+		}
+		reasons = append(reasons, reason)
+	}
+
+	checkQuorum(0)
+	send(0, addNode)
+	checkQuorum(1)
+	expectRevert(send(0, addNode))
+	expectRevert(send(3, removeNode))
+	expectRevert(send(3, submit("BTC", 1)))
+	send(1, addNode)
+	checkQuorum(2)
+	send(2, addNode)
+	checkQuorum(3)
+
+	// test_QuorumEmitsPriceUpdatedEvent: with 3 nodes the 2nd submission finalizes the round
+	emit := solEmit.FindStringSubmatch(spec)
+	if emit == nil {
+		t.Fatal("no PriceUpdated emission in the reference tests")
+	}
+	start, err := chain.client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(0, submit(emit[1], 50000))
+	expectRevert(send(0, submit(emit[1], 50000)))
+	send(1, submit(emit[1], 51000))
+	events, err := chain.contract.FilterPriceUpdated(&bind.FilterOpts{Start: start, Context: ctx}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emitted []*OraclePriceUpdated
+	for events.Next() {
+		emitted = append(emitted, events.Event)
+	}
+	if err := events.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(emitted) != 1 || emitted[0].Coin != crypto.Keccak256Hash([]byte(emit[1])) ||
+		emitted[0].Price.String() != emit[2] || emitted[0].RoundId.String() != emit[3] {
+		t.Errorf("PriceUpdated events = %+v, want %s", emitted, emit[0])
+	}
+
+	send(3, addNode)
+	checkQuorum(4)
+	for nodes := range quorums {
+		t.Errorf("quorum with %d nodes not checked", nodes)
+	}
+
+	// Every revert message of the reference is produced, and no other
+	var want []string
+	for _, match := range solExpectRevert.FindAllStringSubmatch(spec, -1) {
+		if !slices.Contains(want, match[1]) {
+			want = append(want, match[1])
+		}
+	}
+	slices.Sort(want)
+	slices.Sort(reasons)
+	if !slices.Equal(reasons, want) {
+		t.Errorf("revert messages = %q, reference tests expect %q", reasons, want)
+	}
+}
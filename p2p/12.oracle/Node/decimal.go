@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// Prices never go through float64: they are parsed from the text of the JSON
+// number into a big.Rat, aggregated as exact rationals, then rounded once when
+// scaled to the fixed point of the feed. Nodes seeing the same quotes therefore
+// submit the same integer and land in the same round
+
+// Decimal numbers accepted as prices: JSON numbers, which exchanges also send as strings
+// Fractions ("1/3"), base prefixes ("0x10"), NaN and infinities are refused
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)(?:[eE]([+-]?\d+))?$`)
+
+// Limits on a price text, so a broken or hostile API cannot make us allocate huge numbers
+const (
+	maxDecimalLength   = 100 // Characters
+	maxDecimalExponent = 100 // Absolute value of the exponent
+)
+
+var bigTen = big.NewRat(10, 1)
+
+// Parse a decimal number exactly
+func parseDecimal(text string) (*big.Rat, error) {
+	if len(text) > maxDecimalLength {
+		return nil, fmt.Errorf("number %.20q... longer than %d characters", text, maxDecimalLength)
+	}
+	match := decimalPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, fmt.Errorf("invalid decimal number %q", text)
+	}
+	if match[2] != "" {
+		exponent, err := strconv.Atoi(match[2])
+		if err != nil || exponent < -maxDecimalExponent || exponent > maxDecimalExponent {
+			return nil, fmt.Errorf("exponent of %q out of range", text)
+		}
+	}
+	value, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal number %q", text)
+	}
+	return value, nil
+}
+
+// Round a rational to the nearest integer, halfway values away from zero
+// (0.5 -> 1, 1.5 -> 2, 2.5 -> 3, -0.5 -> -1)
+func roundHalfAwayFromZero(value *big.Rat) *big.Int {
+	// floor((2|num| + den) / 2den) on the magnitude, then restore the sign
+	twiceDen := new(big.Int).Lsh(value.Denom(), 1)
+	result := new(big.Int).Abs(value.Num())
+	result.Lsh(result, 1)
+	result.Add(result, value.Denom())
+	result.Quo(result, twiceDen)
+	if value.Sign() < 0 {
+		result.Neg(result)
+	}
+	return result
+}
+
+// Shortest decimal string of a price, exact for anything parsed by parseDecimal
+// Values without a finite decimal expansion (ex: a mean of three) are rounded
+func formatDecimal(value *big.Rat) string {
+	digits := 0
+	scaled := new(big.Rat).Set(value)
+	for !scaled.IsInt() && digits < maxDecimalLength+maxDecimalExponent {
+		scaled.Mul(scaled, bigTen)
+		digits++
+	}
+	return value.FloatString(digits)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func mustParseDecimal(t *testing.T, text string) *big.Rat {
+	t.Helper()
+	value, err := parseDecimal(text)
+	if err != nil {
+		t.Fatalf("parseDecimal(%q): %v", text, err)
+	}
+	return value
+}
+
+func TestParseDecimal(t *testing.T) {
+	valid := map[string]string{
+		"0":                    "0",
+		"1":                    "1",
+		"1.":                   "1",
+		".5":                   "1/2",
+		"+2.50":                "5/2",
+		"-0.1":                 "-1/10",
+		"0.1":                  "1/10", // Not 0.1000000000000000055511151231257827 as a float64
+		"0.00000001":           "1/100000000",
+		"1e-8":                 "1/100000000",
+		"1.5E+3":               "1500",
+		"007":                  "7",
+		"9007199254740993":     "9007199254740993", // 2^53 + 1, not representable as a float64
+		"12345678901234567890": "12345678901234567890",
+		"123456789012345678901234567890.123456789": "123456789012345678901234567890123456789/1000000000",
+	}
+	for text, want := range valid {
+		if got := mustParseDecimal(t, text).RatString(); got != want {
+			t.Errorf("parseDecimal(%q) = %s, want %s", text, got, want)
+		}
+	}
+
+	invalid := []string{
+		"", " 1", "1 ", ".", "-", "e5", "1e", "1e+", "1.2.3", "1,5", "1_000",
+		"1/3", "0x10", "0b1", "0o7", "0x1p-2", "NaN", "Inf", "-Infinity",
+		"1e101", "1e-101", "1e99999999999999999999",
+		strings.Repeat("1", maxDecimalLength+1),
+	}
+	for _, text := range invalid {
+		if value, err := parseDecimal(text); err == nil {
+			t.Errorf("parseDecimal(%q) = %s, want an error", text, value.RatString())
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	for _, v := range []interface{}{json.Number("3000.12"), "3000.12"} {
+		price, err := parsePrice(v)
+		if err != nil || price.RatString() != "75003/25" {
+			t.Errorf("parsePrice(%#v) = %v, %v, want 75003/25", v, price, err)
+		}
+	}
+	if _, err := parsePrice(3000.12); err == nil {
+		t.Errorf("parsePrice(float64) should fail, prices must keep their decimal text")
+	}
+}
+
+func TestRoundHalfAwayFromZero(t *testing.T) {
+	tests := map[string]string{
+		"0":      "0",
+		"0.4999": "0",
+		"0.5":    "1",
+		"1.5":    "2",
+		"2.5":    "3",
+		"2.4999": "2",
+		"-0.5":   "-1",
+		"-1.5":   "-2",
+		"-2.4":   "-2",
+		"7":      "7",
+	}
+	for text, want := range tests {
+		if got := roundHalfAwayFromZero(mustParseDecimal(t, text)).String(); got != want {
+			t.Errorf("round(%s) = %s, want %s", text, got, want)
+		}
+	}
+	if got := roundHalfAwayFromZero(big.NewRat(2, 3)).String(); got != "1" {
+		t.Errorf("round(2/3) = %s, want 1", got)
+	}
+	if got := roundHalfAwayFromZero(big.NewRat(-1, 3)).String(); got != "0" {
+		t.Errorf("round(-1/3) = %s, want 0", got)
+	}
+}
+
+func TestFeedToChain(t *testing.T) {
+	tests := []struct {
+		price    string
+		decimals int
+		want     string
+	}{
+		{"50000.25", 8, "5000025000000"},
+		{"3000.12", 8, "300012000000"},
+		{"0.1", 8, "10000000"},
+		{"0.29", 8, "29000000"}, // 28999999 when truncated through a float64
+		{"4.35", 8, "435000000"},
+		{"1.005", 2, "101"}, // Half rounds up, 100 when scaled as a float64
+
+		// Below the precision of the feed
+		{"0.000000015", 8, "2"},
+		{"0.0000000149", 8, "1"},
+		{"0.000000005", 8, "1"},
+		{"0.000000004999999", 8, "0"},
+		{"1e-30", 8, "0"},
+
+		// Large prices and 18-decimal feeds
+		{"123456789012345678901234567890.123456789", 8, "12345678901234567890123456789012345679"},
+		{"0.053123456789012345678", 18, "53123456789012346"},
+		{"1e30", 18, "1000000000000000000000000000000000000000000000000"},
+		{"9007199254740993", 0, "9007199254740993"},
+		{"2.5", 0, "3"},
+	}
+	for _, test := range tests {
+		feed := Feed{Key: "TEST/USD", Decimals: test.decimals}
+		if got := feed.ToChain(mustParseDecimal(t, test.price)).String(); got != test.want {
+			t.Errorf("ToChain(%s, %d decimals) = %s, want %s", test.price, test.decimals, got, test.want)
+		}
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := map[string]string{
+		"0":          "0",
+		"1500":       "1500",
+		"1.50":       "1.5",
+		"0.00000001": "0.00000001",
+		"-2.25":      "-2.25",
+		"1e-20":      "0.00000000000000000001",
+		"123456789012345678901234567890.123456789": "123456789012345678901234567890.123456789",
+	}
+	for text, want := range tests {
+		if got := formatDecimal(mustParseDecimal(t, text)); got != want {
+			t.Errorf("formatDecimal(%s) = %s, want %s", text, got, want)
+		}
+	}
+
+	// No finite expansion, rounded instead of looping forever
+	if got := formatDecimal(big.NewRat(2, 3)); !strings.HasPrefix(got, "0.6666") || !strings.HasSuffix(got, "7") {
+		t.Errorf("formatDecimal(2/3) = %s", got)
+	}
+}
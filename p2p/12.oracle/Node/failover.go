@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// An endpoint this many blocks behind the best one is taken out of rotation
+const maxEndpointLagBlocks = 5
+
+// rpcEndpoint is one RPC URL of a FailoverClient
+type rpcEndpoint struct {
+	url  string
+	name string // URL without path, query or credentials, API keys often sit there
+
+	mu      sync.Mutex
+	client  *ethclient.Client // Dialed on first use, redialed by the health checks
+	healthy bool
+	head    uint64
+	lastErr error
+}
+
+// Dial the endpoint if needed, a websocket client reconnects by itself afterwards
+func (e *rpcEndpoint) dial(ctx context.Context) (*ethclient.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		return e.client, nil
+	}
+	client, err := ethclient.DialContext(ctx, e.url)
+	if err != nil {
+		return nil, err
+	}
+	e.client = client
+	return client, nil
+}
+
+// EndpointStatus is the health of one endpoint, for /readyz
+type EndpointStatus struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	Head    uint64 `json:"head,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// FailoverClient is a ChainBackend over several RPC endpoints. Calls go to the
+// first healthy endpoint in configuration order; transient failures take the
+// endpoint out of rotation and are retried on the next one with exponential
+// backoff. Health checks bring endpoints back once they answer and follow the
+// chain again, so calls return to the primary endpoint
+type FailoverClient struct {
+	endpoints []*rpcEndpoint
+	retries   int
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	chainID *big.Int
+
+	stop    context.CancelFunc
+	stopped chan struct{}
+}
+
+// DialFailover connects to the endpoints and health checks them every interval
+// until Close. It fails only when no endpoint can be dialed
+func DialFailover(ctx context.Context, urls []string, retries int, interval time.Duration, logger *slog.Logger) (*FailoverClient, error) {
+	c := &FailoverClient{retries: retries, logger: logger, stopped: make(chan struct{})}
+	var errs []error
+	for _, rawURL := range urls {
+		endpoint := &rpcEndpoint{url: rawURL, name: endpointName(rawURL)}
+		if _, err := endpoint.dial(ctx); err != nil {
+			endpoint.lastErr = err
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint.name, err))
+		}
+		c.endpoints = append(c.endpoints, endpoint)
+	}
+	if len(errs) == len(urls) {
+		return nil, errors.Join(errs...)
+	}
+	for _, err := range errs {
+		logger.Warn("RPC endpoint unreachable, retried by the health checks", "error", err)
+	}
+
+	c.checkHealth(ctx)
+
+	checkCtx, stop := context.WithCancel(context.Background())
+	c.stop = stop
+	go func() {
+		defer close(c.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-checkCtx.Done():
+				return
+			case <-ticker.C:
+				c.checkHealth(checkCtx)
+			}
+		}
+	}()
+	return c, nil
+}
+
+// Close stops the health checks and closes every connection
+func (c *FailoverClient) Close() {
+	c.stop()
+	<-c.stopped
+	for _, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.client != nil {
+			endpoint.client.Close()
+		}
+		endpoint.mu.Unlock()
+	}
+}
+
+// Status returns the health of every endpoint, in configuration order
+func (c *FailoverClient) Status() []EndpointStatus {
+	statuses := make([]EndpointStatus, len(c.endpoints))
+	for i, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		statuses[i] = EndpointStatus{URL: endpoint.name, Healthy: endpoint.healthy, Head: endpoint.head}
+		if endpoint.lastErr != nil {
+			statuses[i].Error = endpoint.lastErr.Error()
+		}
+		endpoint.mu.Unlock()
+	}
+	return statuses
+}
+
+// Query the head of every endpoint: the ones that answer, serve the chain of
+// the node and are not lagging behind the others are healthy
+func (c *FailoverClient) checkHealth(ctx context.Context) {
+	heads := make([]uint64, len(c.endpoints))
+	errs := make([]error, len(c.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range c.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			heads[i], errs[i] = c.probe(ctx, endpoint)
+		}()
+	}
+	wg.Wait()
+
+	var best uint64
+	for i := range c.endpoints {
+		if errs[i] == nil {
+			best = max(best, heads[i])
+		}
+	}
+	for i, endpoint := range c.endpoints {
+		err := errs[i]
+		if err == nil && best-heads[i] > maxEndpointLagBlocks {
+			err = fmt.Errorf("%d blocks behind", best-heads[i])
+		}
+		c.setHealth(endpoint, heads[i], err)
+	}
+}
+
+// Head block of an endpoint, after checking it serves the same chain as the others
+func (c *FailoverClient) probe(ctx context.Context, endpoint *rpcEndpoint) (uint64, error) {
+	client, err := endpoint.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	if c.chainID == nil {
+		c.chainID = chainID
+	}
+	expected := c.chainID
+	c.mu.Unlock()
+	if chainID.Cmp(expected) != 0 {
+		return 0, fmt.Errorf("chain ID %s, expected %s", chainID, expected)
+	}
+	return client.BlockNumber(ctx)
+}
+
+// Record the outcome of a health check or a call, logging transitions
+func (c *FailoverClient) setHealth(endpoint *rpcEndpoint, head uint64, err error) {
+	endpoint.mu.Lock()
+	wasHealthy := endpoint.healthy
+	endpoint.healthy = err == nil
+	endpoint.lastErr = err
+	if err == nil {
+		endpoint.head = head
+	}
+	endpoint.mu.Unlock()
+
+	switch {
+	case wasHealthy && err != nil:
+		c.logger.Warn("RPC endpoint out of rotation", "endpoint", endpoint.name, "error", err)
+	case !wasHealthy && err == nil:
+		c.logger.Info("RPC endpoint healthy", "endpoint", endpoint.name, "block", head)
+	}
+}
+
+// Endpoint of the given attempt: the first healthy one, or every endpoint in
+// turn when none passed its last health check
+func (c *FailoverClient) pick(attempt int) *rpcEndpoint {
+	for _, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		healthy := endpoint.healthy
+		endpoint.mu.Unlock()
+		if healthy {
+			return endpoint
+		}
+	}
+	return c.endpoints[attempt%len(c.endpoints)]
+}
+
+// Run a call on the endpoints, retrying transient failures with backoff
+// Any other error (revert, nonce too low...) is returned right away
+func (c *FailoverClient) call(ctx context.Context, fn func(*ethclient.Client) error) error {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(retryBackoff(attempt - 1)):
+			}
+		}
+
+		endpoint := c.pick(attempt)
+		var client *ethclient.Client
+		if client, err = endpoint.dial(ctx); err == nil {
+			if err = fn(client); err == nil {
+				return nil
+			}
+		}
+		if ctx.Err() != nil || !errors.Is(classifyError(err), ErrRPCDown) {
+			return err
+		}
+		c.setHealth(endpoint, 0, err)
+	}
+	return err
+}
+
+// Address of an endpoint safe to log: scheme and host only
+func endpointName(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "invalid URL"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+func (c *FailoverClient) ChainID(ctx context.Context) (*big.Int, error) {
+	c.mu.Lock()
+	chainID := c.chainID
+	c.mu.Unlock()
+	if chainID != nil {
+		return new(big.Int).Set(chainID), nil
+	}
+	err := c.call(ctx, func(client *ethclient.Client) (err error) {
+		chainID, err = client.ChainID(ctx)
+		return err
+	})
+	return chainID, err
+}
+
+func (c *FailoverClient) BlockNumber(ctx context.Context) (number uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		number, err = client.BlockNumber(ctx)
+		return err
+	})
+	return number, err
+}
+
+func (c *FailoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (c *FailoverClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		code, err = client.CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return code, err
+}
+
+func (c *FailoverClient) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		code, err = client.PendingCodeAt(ctx, account)
+		return err
+	})
+	return code, err
+}
+
+func (c *FailoverClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (result []byte, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		result, err = client.CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return result, err
+}
+
+func (c *FailoverClient) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (gas uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		gas, err = client.EstimateGas(ctx, msg)
+		return err
+	})
+	return gas, err
+}
+
+func (c *FailoverClient) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		price, err = client.SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+func (c *FailoverClient) SuggestGasTipCap(ctx context.Context) (tip *big.Int, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		tip, err = client.SuggestGasTipCap(ctx)
+		return err
+	})
+	return tip, err
+}
+
+// SendTransaction broadcasts through the first healthy endpoint. When a retry
+// finds the transaction already known, an earlier attempt reached a txpool
+func (c *FailoverClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	attempts := 0
+	return c.call(ctx, func(client *ethclient.Client) error {
+		attempts++
+		err := client.SendTransaction(ctx, tx)
+		if err != nil && attempts > 1 && strings.Contains(strings.ToLower(err.Error()), "already known") {
+			return nil
+		}
+		return err
+	})
+}
+
+func (c *FailoverClient) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		nonce, err = client.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+func (c *FailoverClient) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (nonce uint64, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		nonce, err = client.NonceAt(ctx, account, blockNumber)
+		return err
+	})
+	return nonce, err
+}
+
+func (c *FailoverClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (balance *big.Int, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		balance, err = client.BalanceAt(ctx, account, blockNumber)
+		return err
+	})
+	return balance, err
+}
+
+func (c *FailoverClient) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) (value []byte, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		value, err = client.StorageAt(ctx, account, key, blockNumber)
+		return err
+	})
+	return value, err
+}
+
+func (c *FailoverClient) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		tx, isPending, err = client.TransactionByHash(ctx, hash)
+		return err
+	})
+	return tx, isPending, err
+}
+
+func (c *FailoverClient) TransactionReceipt(ctx context.Context, hash common.Hash) (receipt *types.Receipt, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		receipt, err = client.TransactionReceipt(ctx, hash)
+		return err
+	})
+	return receipt, err
+}
+
+func (c *FailoverClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = c.call(ctx, func(client *ethclient.Client) (err error) {
+		logs, err = client.FilterLogs(ctx, query)
+		return err
+	})
+	return logs, err
+}
+
+// SubscribeFilterLogs subscribes on the first healthy endpoint that supports
+// subscriptions; when it drops, subscribing again fails over to the next one
+func (c *FailoverClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return c.subscribe(ctx, func(client *ethclient.Client) (ethereum.Subscription, error) {
+		return client.SubscribeFilterLogs(ctx, query, ch)
+	})
+}
+
+func (c *FailoverClient) SubscribeTransactionReceipts(ctx context.Context, query *ethereum.TransactionReceiptsQuery, ch chan<- []*types.Receipt) (ethereum.Subscription, error) {
+	return c.subscribe(ctx, func(client *ethclient.Client) (ethereum.Subscription, error) {
+		return client.SubscribeTransactionReceipts(ctx, query, ch)
+	})
+}
+
+// Subscribe on the healthy endpoints first, then on the others, skipping
+// those that do not support subscriptions
+func (c *FailoverClient) subscribe(ctx context.Context, fn func(*ethclient.Client) (ethereum.Subscription, error)) (ethereum.Subscription, error) {
+	var healthy, others []*rpcEndpoint
+	for _, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.healthy {
+			healthy = append(healthy, endpoint)
+		} else {
+			others = append(others, endpoint)
+		}
+		endpoint.mu.Unlock()
+	}
+
+	var err error = rpc.ErrNotificationsUnsupported
+	for _, endpoint := range append(healthy, others...) {
+		client, dialErr := endpoint.dial(ctx)
+		if dialErr != nil {
+			err = dialErr
+			continue
+		}
+		sub, subErr := fn(client)
+		if subErr == nil {
+			return sub, nil
+		}
+		if errors.Is(subErr, rpc.ErrNotificationsUnsupported) {
+			continue
+		}
+		err = subErr
+		if errors.Is(classifyError(subErr), ErrRPCDown) {
+			c.setHealth(endpoint, 0, subErr)
+		}
+	}
+	return nil, err
+}
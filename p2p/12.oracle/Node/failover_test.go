@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// mockRPC is a JSON-RPC endpoint answering the chain ID, the head and balances
+type mockRPC struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	head     uint64
+	balance  uint64
+	down     bool  // Every request fails with 503
+	failures []int // HTTP statuses of the next requests
+	requests int
+}
+
+func newMockRPC(t *testing.T, head, balance uint64) *mockRPC {
+	m := &mockRPC{head: head, balance: balance}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// SetDown makes every request fail until called with false
+func (m *mockRPC) SetDown(down bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.down = down
+}
+
+// FailNext makes the next requests fail with the given HTTP statuses
+func (m *mockRPC) FailNext(statuses ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, statuses...)
+}
+
+// Requests returns how many requests reached the server
+func (m *mockRPC) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+func (m *mockRPC) serve(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.requests++
+	status := http.StatusOK
+	if m.down {
+		status = http.StatusServiceUnavailable
+	} else if len(m.failures) > 0 {
+		status, m.failures = m.failures[0], m.failures[1:]
+	}
+	head, balance := m.head, m.balance
+	m.mu.Unlock()
+
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+	switch request.Method {
+	case "eth_chainId":
+		response["result"] = hexutil.Uint64(1337)
+	case "eth_blockNumber":
+		response["result"] = hexutil.Uint64(head)
+	case "eth_getBalance":
+		response["result"] = hexutil.Uint64(balance)
+	default:
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func dialTestFailover(t *testing.T, retries int, urls ...string) *FailoverClient {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	client, err := DialFailover(testContext(t), urls, retries, time.Hour, logger)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func endpointHealth(client *FailoverClient) []bool {
+	var healthy []bool
+	for _, status := range client.Status() {
+		healthy = append(healthy, status.Healthy)
+	}
+	return healthy
+}
+
+func TestFailoverToHealthyEndpoint(t *testing.T) {
+	ctx := testContext(t)
+	primary := newMockRPC(t, 100, 1)
+	backup := newMockRPC(t, 100, 2)
+	primary.SetDown(true)
+	client := dialTestFailover(t, 0, primary.URL, backup.URL)
+
+	if health := endpointHealth(client); health[0] || !health[1] {
+		t.Fatalf("health = %v, want [false true]", health)
+	}
+	balance, err := client.BalanceAt(ctx, common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance.Uint64() != 2 {
+		t.Fatalf("balance = %s, want 2 from the backup", balance)
+	}
+
+	// Calls return to the primary once a health check sees it back
+	primary.SetDown(false)
+	client.checkHealth(ctx)
+	if balance, err = client.BalanceAt(ctx, common.Address{}, nil); err != nil || balance.Uint64() != 1 {
+		t.Fatalf("balance = %v, %v, want 1 from the primary", balance, err)
+	}
+}
+
+func TestFailoverDuringCall(t *testing.T) {
+	ctx := testContext(t)
+	primary := newMockRPC(t, 100, 1)
+	backup := newMockRPC(t, 100, 2)
+	client := dialTestFailover(t, 1, primary.URL, backup.URL)
+
+	primary.SetDown(true)
+	balance, err := client.BalanceAt(ctx, common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance.Uint64() != 2 {
+		t.Fatalf("balance = %s, want 2 from the backup", balance)
+	}
+	if health := endpointHealth(client); health[0] || !health[1] {
+		t.Fatalf("health = %v, want the primary out of rotation", health)
+	}
+}
+
+func TestFailoverDropsLaggingEndpoint(t *testing.T) {
+	primary := newMockRPC(t, 100, 1)
+	backup := newMockRPC(t, 100+maxEndpointLagBlocks+1, 2)
+	client := dialTestFailover(t, 0, primary.URL, backup.URL)
+
+	status := client.Status()
+	if status[0].Healthy || !status[1].Healthy {
+		t.Fatalf("status = %+v, want the lagging primary out of rotation", status)
+	}
+}
+
+func TestFailoverRetriesTransientErrors(t *testing.T) {
+	ctx := testContext(t)
+	endpoint := newMockRPC(t, 100, 7)
+	client := dialTestFailover(t, 3, endpoint.URL)
+
+	endpoint.FailNext(http.StatusBadGateway, http.StatusTooManyRequests)
+	before := endpoint.Requests()
+	balance, err := client.BalanceAt(ctx, common.Address{}, nil)
+	if err != nil {
+		t.Fatalf("balance: %v", err)
+	}
+	if balance.Uint64() != 7 {
+		t.Fatalf("balance = %s, want 7", balance)
+	}
+	if requests := endpoint.Requests() - before; requests != 3 {
+		t.Fatalf("requests = %d, want 2 failures then a success", requests)
+	}
+
+	// Errors other than an unavailable endpoint are not retried
+	before = endpoint.Requests()
+	if _, err := client.PendingNonceAt(ctx, common.Address{}); err == nil {
+		t.Fatal("unknown method succeeded")
+	}
+	if requests := endpoint.Requests() - before; requests != 1 {
+		t.Fatalf("requests = %d, want 1", requests)
+	}
+}
+
+func TestDialFailoverNeedsOneEndpoint(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	_, err := DialFailover(context.Background(), []string{"unknown://localhost"}, 0, time.Hour, logger)
+	if err == nil {
+		t.Fatal("dial succeeded without a reachable endpoint")
+	}
+}
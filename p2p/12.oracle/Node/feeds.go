@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Decimals of the fixed-point prices of the workshop contract and frontend
+const defaultDecimals = 8
+
+// Largest number of decimals a feed may use, enough for 18-decimal fixed point
+const maxDecimals = 30
+
+// FeedConfig declares a price feed as a pair in the config file
+type FeedConfig struct {
+	// Base and quote tickers, also the on-chain key (ex: ETH/USD, BTC/EUR, ETH/BTC)
+	Pair string `yaml:"pair"`
+
+	// CoinGecko ID of the base asset, derived from its ticker when empty (ex: ethereum)
+	Coin string `yaml:"coin"`
+
+	// Decimals of the fixed-point price submitted on-chain (default 8)
+	Decimals *int `yaml:"decimals"`
+}
+
+// Feed is a price the node submits: one base asset quoted in another currency
+type Feed struct {
+	Key      string // Key of the feed in the contract (ex: "ETH/USD")
+	Coin     string // CoinGecko ID of the base asset (ex: "ethereum")
+	Base     string // Ticker of the base asset (ex: "ETH"), empty when unknown
+	Quote    string // Quote currency (ex: "USD")
+	Decimals int    // Decimals of the on-chain fixed-point price
+}
+
+func (f Feed) String() string { return f.Key }
+
+// ToChain scales a price to the fixed point of the feed, rounding half away from zero
+// Example with 8 decimals: 50000.25 -> 5000025000000 (50000.25 * 10^8)
+// and 0.000000015 -> 2, 0.0000000149 -> 1
+func (f Feed) ToChain(price *big.Rat) *big.Int {
+	scaled := new(big.Rat).Mul(price, new(big.Rat).SetInt(pow10(f.Decimals)))
+	return roundHalfAwayFromZero(scaled)
+}
+
+// Format formats an on-chain price of the feed as a decimal string
+func (f Feed) Format(price *big.Int) string {
+	return formatPrice(price, f.Decimals)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Format a fixed-point price with the given decimals as a decimal string
+func formatPrice(price *big.Int, decimals int) string {
+	if price == nil {
+		return ""
+	}
+	return new(big.Rat).SetFrac(price, pow10(decimals)).FloatString(decimals)
+}
+
+// CoinGecko ID of a ticker, the reverse of coinSymbols
+func coinID(symbol string) (string, bool) {
+	for id, s := range coinSymbols {
+		if strings.EqualFold(s, symbol) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Build the feed of a pair declared in the config file
+func parseFeed(cfg FeedConfig) (Feed, error) {
+	base, quote, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(cfg.Pair)), "/")
+	if !ok || base == "" || quote == "" || strings.Contains(quote, "/") {
+		return Feed{}, fmt.Errorf("invalid pair %q, expected BASE/QUOTE (ex: ETH/USD)", cfg.Pair)
+	}
+
+	feed := Feed{Key: base + "/" + quote, Coin: cfg.Coin, Base: base, Quote: quote, Decimals: defaultDecimals}
+	if feed.Coin == "" {
+		if feed.Coin, ok = coinID(base); !ok {
+			return Feed{}, fmt.Errorf("pair %s: no CoinGecko ID known for %s, set coin", feed.Key, base)
+		}
+	}
+	if cfg.Decimals != nil {
+		if *cfg.Decimals < 0 || *cfg.Decimals > maxDecimals {
+			return Feed{}, fmt.Errorf("pair %s: decimals must be between 0 and %d", feed.Key, maxDecimals)
+		}
+		feed.Decimals = *cfg.Decimals
+	}
+	return feed, nil
+}
+
+// ParseFeeds returns every feed of the node: the coins, quoted in USD with 8 decimals
+// under their bare CoinGecko ID as the workshop contract expects, then the pairs
+func (c *Config) ParseFeeds() ([]Feed, error) {
+	var feeds []Feed
+	seen := make(map[string]bool)
+	add := func(feed Feed) error {
+		if seen[feed.Key] {
+			return fmt.Errorf("feed %s declared twice", feed.Key)
+		}
+		seen[feed.Key] = true
+		feeds = append(feeds, feed)
+		return nil
+	}
+
+	for _, coin := range c.Coins {
+		base, _ := coinSymbol(coin, "")
+		if err := add(Feed{Key: coin, Coin: coin, Base: base, Quote: "USD", Decimals: defaultDecimals}); err != nil {
+			return nil, err
+		}
+	}
+	for _, feedConfig := range c.Feeds {
+		feed, err := parseFeed(feedConfig)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(feed); err != nil {
+			return nil, err
+		}
+	}
+	return feeds, nil
+}
+
+// Keys of a list of feeds
+func feedKeys(feeds []Feed) []string {
+	keys := make([]string, len(feeds))
+	for i, feed := range feeds {
+		keys[i] = feed.Key
+	}
+	return keys
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// Fetch tuning, shared by every source
+const (
+	priceCacheTTL    = 5 * time.Second // Nodes asking for the same quote within this window share it
+	maxFetchRetries  = 3               // Retries of a rate-limited or failing request
+	retryBaseBackoff = 500 * time.Millisecond
+	retryMaxBackoff  = 30 * time.Second
+)
+
+// Requests per second allowed by default on each provider, per API key
+var defaultRateLimits = map[string]float64{
+	"coingecko": 0.5, // 30 calls/min on the free and demo plans
+	"binance":   10,
+	"kraken":    1,
+	"coinbase":  10,
+	"json":      1,
+}
+
+// HTTPStatusError is returned by getJSON when a provider answers with an error status
+type HTTPStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // Delay requested by the Retry-After header, 0 when absent
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("API request failed with status: %d", e.StatusCode)
+}
+
+// Rate limited or temporarily failing requests are worth retrying
+func (e *HTTPStatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Parse a Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// Exponential backoff with full jitter: a random delay up to base * 2^attempt
+func retryBackoff(attempt int) time.Duration {
+	backoff := retryBaseBackoff << attempt
+	if backoff > retryMaxBackoff || backoff <= 0 {
+		backoff = retryMaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff))) + time.Millisecond
+}
+
+// Throttle of one provider and API key
+type providerLimiter struct {
+	limiter *rate.Limiter
+
+	mu           sync.Mutex
+	blockedUntil time.Time // Set by a 429, every caller waits until then
+}
+
+// Wait for a pause requested by the provider, then for a token
+func (l *providerLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.blockedUntil)
+	l.mu.Unlock()
+	if pause > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Hold every caller back until the provider accepts requests again
+func (l *providerLimiter) block(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// Fetcher is shared by all the nodes of the process so they never query a
+// provider more than its rate limit allows: one token bucket per provider and
+// API key, identical queries coalesced, and answers cached for a short time
+type Fetcher struct {
+	ttl   time.Duration
+	group singleflight.Group
+
+	// Lifetime of the shared requests, which outlive the callers that started them
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	limiters map[string]*providerLimiter
+	cache    map[string]cachedPrice
+}
+
+// Prices are shared between callers and must never be modified
+type cachedPrice struct {
+	price     *big.Rat
+	fetchedAt time.Time
+}
+
+// NewFetcher creates a fetcher caching prices for ttl
+func NewFetcher(ttl time.Duration) *Fetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Fetcher{
+		ttl:      ttl,
+		ctx:      ctx,
+		cancel:   cancel,
+		limiters: make(map[string]*providerLimiter),
+		cache:    make(map[string]cachedPrice),
+	}
+}
+
+// Close cancels the requests in flight and their retries, once the nodes stopped
+func (f *Fetcher) Close() {
+	f.cancel()
+}
+
+// Fetcher of the process, shared by every node
+var sharedFetcher = NewFetcher(priceCacheTTL)
+
+// Get the limiter of a provider and API key, creating it on first use
+func (f *Fetcher) limiter(key string, perSecond float64) *providerLimiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.limiters[key]
+	if !ok {
+		l = &providerLimiter{limiter: rate.NewLimiter(rate.Limit(perSecond), max(1, int(perSecond)))}
+		f.limiters[key] = l
+	}
+	return l
+}
+
+// Return a cached price younger than the TTL
+func (f *Fetcher) cached(key string) (*big.Rat, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, ok := f.cache[key]
+	if !ok || time.Since(entry.fetchedAt) > f.ttl {
+		return nil, false
+	}
+	return entry.price, true
+}
+
+func (f *Fetcher) store(key string, price *big.Rat) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cache[key] = cachedPrice{price: price, fetchedAt: time.Now()}
+	// Drop expired entries so coins that are no longer tracked do not pile up
+	for k, entry := range f.cache {
+		if time.Since(entry.fetchedAt) > f.ttl {
+			delete(f.cache, k)
+		}
+	}
+}
+
+// Fetch returns the price of cacheKey from the cache, from an identical request
+// in flight, or by calling fetch once the limiter allows it
+func (f *Fetcher) Fetch(ctx context.Context, limiter *providerLimiter, cacheKey string,
+	fetch func(context.Context) (*big.Rat, error)) (*big.Rat, error) {
+	if price, ok := f.cached(cacheKey); ok {
+		return price, nil
+	}
+
+	result := f.group.DoChan(cacheKey, func() (interface{}, error) {
+		var price *big.Rat
+		err := f.withRetries(ctx, limiter, func(ctx context.Context) (err error) {
+			price, err = fetch(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		f.store(cacheKey, price)
+		return price, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*big.Rat), nil
+	}
+}
+
+// Run a request once the limiter allows it, retrying rate-limited and server errors
+// The request may be shared, so a caller giving up must not cancel it for the others,
+// it only stops with the fetcher
+func (f *Fetcher) withRetries(ctx context.Context, limiter *providerLimiter, request func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	stop := context.AfterFunc(f.ctx, cancel)
+	defer stop()
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.wait(ctx); err != nil {
+			return err
+		}
+		err := request(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *HTTPStatusError
+		if !errors.As(err, &statusErr) || !statusErr.retryable() || attempt >= maxFetchRetries {
+			return err
+		}
+		// Retry-After is honored up to the longest backoff, a provider asking for
+		// hours must not hold every node sharing the limiter that long
+		delay := retryBackoff(attempt)
+		if statusErr.RetryAfter > delay {
+			delay = min(statusErr.RetryAfter, retryMaxBackoff)
+		}
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			limiter.block(delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchedSource routes the requests of a source through the shared fetcher
+type fetchedSource struct {
+	PriceSource
+	fetcher  *Fetcher
+	limiter  *providerLimiter
+	cacheKey string   // Identifies the provider, credentials and market, the feed key is appended
+	metrics  *Metrics // Of the node, nil when not monitored
+}
+
+// Wrap routes a source through the fetcher, throttled per provider and API key
+// The requests the node sends to the provider are observed in metrics, which may be nil
+func (f *Fetcher) Wrap(source PriceSource, cfg SourceConfig, metrics *Metrics) PriceSource {
+	provider := strings.ToLower(cfg.Type)
+	perSecond := cfg.RateLimit
+	if perSecond <= 0 {
+		perSecond = defaultRateLimits[provider]
+	}
+	if perSecond <= 0 {
+		perSecond = float64(rate.Inf)
+	}
+	// Sources with their own URL (json endpoints, CoinGecko proxies) are throttled apart
+	limiterKey := provider + "|" + cfg.APIKey + "|" + cfg.URL
+
+	return &fetchedSource{
+		PriceSource: source,
+		fetcher:     f,
+		limiter:     f.limiter(limiterKey, perSecond),
+		cacheKey:    strings.Join([]string{provider, cfg.APIKey, cfg.URL, cfg.Path, cfg.Symbol}, "|"),
+		metrics:     metrics,
+	}
+}
+
+// Batchable tells whether the provider can quote several coins in one request
+func (s *fetchedSource) Batchable() bool {
+	_, ok := s.PriceSource.(BatchPriceSource)
+	return ok
+}
+
+// FetchPrices quotes the feeds missing from the cache with a single request and
+// caches each answer, so the per-feed FetchPrice calls that follow are free
+func (s *fetchedSource) FetchPrices(ctx context.Context, feeds []Feed) (map[string]*big.Rat, error) {
+	batch, ok := s.PriceSource.(BatchPriceSource)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot quote several feeds at once", s.Name())
+	}
+
+	prices := make(map[string]*big.Rat, len(feeds))
+	var missing []Feed
+	for _, feed := range feeds {
+		if price, ok := s.fetcher.cached(s.cacheKey + "|" + feed.Key); ok {
+			prices[feed.Key] = price
+		} else {
+			missing = append(missing, feed)
+		}
+	}
+	if len(missing) == 0 {
+		return prices, nil
+	}
+	keys := feedKeys(missing)
+	sort.Strings(keys)
+
+	result := s.fetcher.group.DoChan(s.cacheKey+"|"+strings.Join(keys, ","), func() (interface{}, error) {
+		var fetched map[string]*big.Rat
+		err := s.fetcher.withRetries(ctx, s.limiter, func(ctx context.Context) (err error) {
+			start := time.Now()
+			fetched, err = batch.FetchPrices(ctx, missing)
+			s.observe(time.Since(start), err)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for key, price := range fetched {
+			s.fetcher.store(s.cacheKey+"|"+key, price)
+		}
+		return fetched, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		for key, price := range res.Val.(map[string]*big.Rat) {
+			prices[key] = price
+		}
+		return prices, nil
+	}
+}
+
+func (s *fetchedSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	return s.fetcher.Fetch(ctx, s.limiter, s.cacheKey+"|"+feed.Key, func(ctx context.Context) (*big.Rat, error) {
+		start := time.Now()
+		price, err := s.PriceSource.FetchPrice(ctx, feed)
+		s.observe(time.Since(start), err)
+		return price, err
+	})
+}
+
+// Record a request that reached the provider, once per attempt
+// Cache hits and requests shared with another caller are not observed again
+func (s *fetchedSource) observe(duration time.Duration, err error) {
+	if s.metrics != nil {
+		s.metrics.ObserveFetch(s.Name(), duration, err)
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+// Contract bindings are generated from the ABI of the Oracle contract of the
+// Foundry project (../oracle/src/Oracle.sol, deployed by utils/Oracle.sol).
+// After changing the contract, refresh the ABI then run go generate:
+//
+//	(cd ../oracle && forge inspect Oracle abi --json > ../Node/Oracle.abi.json)
+//	go generate ./...
+
+//go:generate go run github.com/ethereum/go-ethereum/cmd/abigen@v1.16.7 --abi Oracle.abi.json --pkg main --type Oracle --out oracle_contract.go
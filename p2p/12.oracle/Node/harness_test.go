@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Chain ID of go-ethereum's simulated backend
+const simulatedChainID = 1337
+
+// How often the test chain mines a block when transactions are pending
+const testBlockInterval = 20 * time.Millisecond
+
+var asmToken = regexp.MustCompile(`"[^"]*"|\S+`)
+
+// assembleContract turns the assembly of testdata/Oracle.easm into bytecode
+func assembleContract(source string) ([]byte, error) {
+	var (
+		code   []byte
+		labels = make(map[string]int)
+		fixups = make(map[int]string) // Offset of a PUSH2 immediate -> label
+	)
+	for lineNumber, line := range strings.Split(source, "\n") {
+		line, _, _ = strings.Cut(line, ";")
+		tokens := asmToken.FindAllString(line, -1)
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			switch {
+			case strings.HasSuffix(token, ":"):
+				labels[strings.TrimSuffix(token, ":")] = len(code)
+				code = append(code, byte(vm.JUMPDEST))
+			case strings.HasPrefix(token, "@"):
+				code = append(code, byte(vm.PUSH2), 0, 0)
+				fixups[len(code)-2] = token[1:]
+			default:
+				op := vm.StringToOp(token)
+				if op == 0 && token != "STOP" {
+					return nil, fmt.Errorf("line %d: unknown instruction %q", lineNumber+1, token)
+				}
+				code = append(code, byte(op))
+				if !op.IsPush() || op == vm.PUSH0 {
+					continue
+				}
+				size := int(op - vm.PUSH0)
+				if i++; i == len(tokens) {
+					return nil, fmt.Errorf("line %d: %s without a value", lineNumber+1, token)
+				}
+				immediate, err := pushValue(tokens[i], size)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNumber+1, err)
+				}
+				code = append(code, immediate...)
+			}
+		}
+	}
+	for offset, label := range fixups {
+		target, ok := labels[label]
+		if !ok {
+			return nil, fmt.Errorf("undefined label %q", label)
+		}
+		code[offset], code[offset+1] = byte(target>>8), byte(target)
+	}
+	return code, nil
+}
+
+// Bytes pushed by a PUSHn: a number right-aligned, or a "string" left-aligned
+func pushValue(token string, size int) ([]byte, error) {
+	value := make([]byte, size)
+	if text, err := strconv.Unquote(token); err == nil {
+		if len(text) > size {
+			return nil, fmt.Errorf("%s does not fit in %d bytes", token, size)
+		}
+		copy(value, text)
+		return value, nil
+	}
+	number, ok := new(big.Int).SetString(token, 0)
+	if !ok || number.Sign() < 0 || len(number.Bytes()) > size {
+		return nil, fmt.Errorf("invalid %d-byte value %s", size, token)
+	}
+	return number.FillBytes(value), nil
+}
+
+// Creation code of the test Oracle: store the deployer as owner, then return the runtime
+func oracleCreationCode(t *testing.T) []byte {
+	t.Helper()
+	source, err := os.ReadFile("testdata/Oracle.easm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime, err := assembleContract(string(source))
+	if err != nil {
+		t.Fatalf("assemble testdata/Oracle.easm: %v", err)
+	}
+	constructor, err := assembleContract(fmt.Sprintf(
+		"CALLER PUSH1 0 SSTORE PUSH2 %d DUP1 PUSH2 17 PUSH1 0 CODECOPY PUSH1 0 RETURN", len(runtime)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(constructor, runtime...)
+}
+
+// testChain is a simulated chain mining pending transactions every testBlockInterval, with
+// the Oracle deployed and funded node accounts
+type testChain struct {
+	backend  *simulated.Backend
+	client   simulated.Client
+	contract *Oracle
+	address  common.Address
+	keys     []*ecdsa.PrivateKey
+}
+
+func newTestChain(t *testing.T, nodes int) *testChain {
+	t.Helper()
+	deployer, _ := crypto.GenerateKey()
+	alloc := types.GenesisAlloc{
+		crypto.PubkeyToAddress(deployer.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	}
+	chain := &testChain{}
+	for i := 0; i < nodes; i++ {
+		key, _ := crypto.GenerateKey()
+		chain.keys = append(chain.keys, key)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = types.Account{Balance: big.NewInt(params.Ether)}
+	}
+
+	chain.backend = simulated.NewBackend(alloc)
+	t.Cleanup(func() { chain.backend.Close() })
+	chain.client = chain.backend.Client()
+
+	opts, err := bind.NewKeyedTransactorWithChainID(deployer, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contractABI, err := OracleMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chain.address, _, _, err = bind.DeployContract(opts, *contractABI, oracleCreationCode(t), chain.client); err != nil {
+		t.Fatalf("deploy Oracle: %v", err)
+	}
+	chain.backend.Commit()
+	if chain.contract, err = NewOracle(chain.address, chain.client); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine in the background like a dev chain, stopped before the backend is closed
+	// Empty blocks are skipped so event filters only scan blocks that matter
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(testBlockInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if chain.hasPending(ctx) {
+					chain.backend.Commit()
+				}
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return chain
+}
+
+// Whether a node account has transactions waiting in the pool
+// The pending block of the simulated backend is not rebuilt, nonces are
+func (c *testChain) hasPending(ctx context.Context) bool {
+	for _, key := range c.keys {
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		pending, err := c.client.PendingNonceAt(ctx, address)
+		if err != nil {
+			return false
+		}
+		confirmed, err := c.client.NonceAt(ctx, address, nil)
+		if err != nil {
+			return false
+		}
+		if pending > confirmed {
+			return true
+		}
+	}
+	return false
+}
+
+// Config of the i-th node of the chain, pricing every feed from the given CoinGecko stand-in
+func (c *testChain) nodeConfig(i int, coingecko *mockCoinGecko) *Config {
+	cfg := defaultConfig()
+	cfg.Coins = []string{defaultCoin}
+	cfg.Name = fmt.Sprintf("node-%d", i)
+	cfg.ID = i
+	cfg.RPCURL = "simulated"
+	cfg.HTTPPort = fmt.Sprintf("127.0.0.1:%d", 18080+i) // Only validated, nothing listens
+	cfg.ContractAddress = c.address.Hex()
+	cfg.PrivateKey = hex.EncodeToString(crypto.FromECDSA(c.keys[i]))
+	cfg.Sources = map[string][]SourceConfig{
+		"ethereum": {{Type: "coingecko", URL: coingecko.URL, RateLimit: 100}},
+	}
+	return &cfg
+}
+
+// Start the i-th node of the chain, closed at the end of the test
+func (c *testChain) newNode(t *testing.T, cfg *Config) *OracleNode {
+	t.Helper()
+	if err := validateConfigs([]*Config{cfg}); err != nil {
+		t.Fatal(err)
+	}
+	node, err := NewOracleNodeWithBackend(context.Background(), cfg, cfg.ID, c.client, c.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Close)
+	return node
+}
+
+// Wait until cond holds, failing the test after timeout
+func eventually(t *testing.T, timeout time.Duration, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out: "+format, args...)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// mockCoinGecko is an httptest stand-in for the CoinGecko simple/price endpoint
+// Tests script the prices, the latency and the status codes it answers with
+type mockCoinGecko struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	prices     map[string]map[string]string // coin -> currency -> JSON number text
+	latency    time.Duration
+	failures   []int  // Status codes of the next requests, consumed in order
+	retryAfter string // Retry-After header sent with the failures
+	requests   int
+}
+
+func newMockCoinGecko(t *testing.T) *mockCoinGecko {
+	m := &mockCoinGecko{prices: make(map[string]map[string]string)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// SetPrice sets the price of a coin in a currency, as the exact JSON number text
+func (m *mockCoinGecko) SetPrice(coin, currency, price string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.prices[coin] == nil {
+		m.prices[coin] = make(map[string]string)
+	}
+	m.prices[coin][currency] = price
+}
+
+// SetLatency delays every answer
+func (m *mockCoinGecko) SetLatency(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = latency
+}
+
+// FailNext answers the next requests with these status codes
+func (m *mockCoinGecko) FailNext(retryAfter string, statuses ...int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, statuses...)
+	m.retryAfter = retryAfter
+}
+
+// Requests returns how many requests reached the server
+func (m *mockCoinGecko) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+func (m *mockCoinGecko) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests++
+	latency := m.latency
+	status := http.StatusOK
+	if len(m.failures) > 0 {
+		status, m.failures = m.failures[0], m.failures[1:]
+	}
+	result := make(map[string]map[string]json.Number)
+	for _, coin := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		for _, currency := range strings.Split(r.URL.Query().Get("vs_currencies"), ",") {
+			if price, ok := m.prices[coin][currency]; ok {
+				if result[coin] == nil {
+					result[coin] = make(map[string]json.Number)
+				}
+				result[coin][currency] = json.Number(price)
+			}
+		}
+	}
+	retryAfter := m.retryAfter
+	m.mu.Unlock()
+
+	select {
+	case <-time.After(latency):
+	case <-r.Context().Done():
+		return
+	}
+	if r.URL.Path != "/simple/price" {
+		http.NotFound(w, r)
+		return
+	}
+	if status != http.StatusOK {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Outcome of a single health check
+const (
+	CheckOK   = "ok"   // Healthy
+	CheckWarn = "warn" // Degraded but still serving, does not fail the probe
+	CheckFail = "fail" // Fails the probe (HTTP 503)
+)
+
+// Result of a cycle that sent a transaction, the others end with a Skip reason
+const CycleSubmitted = "submitted"
+
+// Time allowed to the RPC calls of a probe
+const healthCheckTimeout = 5 * time.Second
+
+// CheckResult is the state of one component reported by /healthz and /readyz
+type CheckResult struct {
+	Status  string      `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// HealthReport is the JSON body of /healthz and /readyz
+type HealthReport struct {
+	Status  string                 `json:"status"`
+	Node    string                 `json:"node"`
+	Address string                 `json:"address"`
+	Time    time.Time              `json:"time"`
+	Checks  map[string]CheckResult `json:"checks"`
+}
+
+// CoinStatus tracks the last fetch and submission cycle of one coin
+type CoinStatus struct {
+	LastFetch     time.Time // Last time the sources were queried
+	SourcesOK     int       // Sources that answered at the last fetch
+	SourcesFailed []string  // Sources that failed at the last fetch
+	LastSuccess   time.Time // Last cycle that completed: price submitted or already in sync
+	LastSubmitted time.Time // Last submission mined successfully
+	LastCycle     time.Time // Last cycle that reached a decision, paused ones included
+	LastResult    string    // CycleSubmitted or the Skip reason of the last cycle
+
+	// Last submission transaction, whatever its outcome
+	Submission *HistoryRecord
+}
+
+// Get the status of a coin, creating it on first use. n.mu must be held.
+func (n *OracleNode) coinStatusLocked(coin string) *CoinStatus {
+	status, ok := n.coinStatus[coin]
+	if !ok {
+		status = &CoinStatus{}
+		n.coinStatus[coin] = status
+	}
+	return status
+}
+
+// Record the outcome of a submission cycle, CycleSubmitted or a Skip reason
+// A cycle paused for a low balance ran but did not succeed
+func (n *OracleNode) recordCycle(coin string, result string) {
+	now := time.Now()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	status := n.coinStatusLocked(coin)
+	status.LastCycle = now
+	status.LastResult = result
+	if result != SkipLowBalance {
+		status.LastSuccess = now
+	}
+	if result == CycleSubmitted {
+		status.LastSubmitted = now
+	}
+}
+
+// Record which sources answered for a coin
+func (n *OracleNode) recordFetch(coin string, ok int, failed []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	status := n.coinStatusLocked(coin)
+	status.LastFetch = time.Now()
+	status.SourcesOK = ok
+	status.SourcesFailed = failed
+}
+
+// Longest time a coin may go without a completed cycle before the node is unhealthy
+func (n *OracleNode) staleAfter() time.Duration {
+	return time.Duration(n.config.StaleIntervals*n.config.SubmissionInterval) * time.Second
+}
+
+// Liveness: the submission loop is not stuck, a failure means restarting the node may help
+// External dependencies (RPC, sources) are left to readiness, a restart would not fix them
+func (n *OracleNode) livenessChecks(ctx context.Context) map[string]CheckResult {
+	checks := make(map[string]CheckResult)
+
+	n.mu.Lock()
+	lastTick := n.lastTick
+	n.mu.Unlock()
+	switch age := time.Since(lastTick); {
+	case lastTick.IsZero():
+		checks["loop"] = CheckResult{Status: CheckWarn, Message: "submission loop not started yet"}
+	case age > n.staleAfter():
+		checks["loop"] = CheckResult{Status: CheckFail, Message: fmt.Sprintf("no loop iteration for %s", age.Round(time.Second))}
+	default:
+		checks["loop"] = CheckResult{Status: CheckOK, Details: map[string]interface{}{"last_tick": lastTick}}
+	}
+	return checks
+}
+
+// Readiness: the node can and does submit prices
+func (n *OracleNode) readinessChecks(ctx context.Context) map[string]CheckResult {
+	return map[string]CheckResult{
+		"rpc":          n.checkRPC(ctx),
+		"registration": n.checkRegistration(ctx),
+		"balance":      n.checkBalance(),
+		"submissions":  n.checkSubmissions(),
+		"sources":      n.checkSources(),
+	}
+}
+
+// Check the RPC endpoint answers and its head block is recent
+func (n *OracleNode) checkRPC(ctx context.Context) CheckResult {
+	head, err := n.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: fmt.Sprintf("RPC unreachable: %v", err)}
+	}
+
+	headTime := time.Unix(int64(head.Time), 0)
+	age := time.Since(headTime)
+	details := map[string]interface{}{"block": head.Number.Uint64(), "block_time": headTime, "age_seconds": int64(age.Seconds())}
+	maxAge := time.Duration(n.config.MaxHeadAge) * time.Second
+	if maxAge > 0 && age > maxAge {
+		return CheckResult{Status: CheckFail, Message: fmt.Sprintf("head block is %s old", age.Round(time.Second)), Details: details}
+	}
+
+	// With fallbacks, losing some endpoints degrades the node without stopping it
+	if failover, ok := n.client.(*FailoverClient); ok {
+		endpoints := failover.Status()
+		details["endpoints"] = endpoints
+		down := 0
+		for _, endpoint := range endpoints {
+			if !endpoint.Healthy {
+				down++
+			}
+		}
+		if down > 0 {
+			return CheckResult{Status: CheckWarn, Message: fmt.Sprintf("%d of %d RPC endpoints unhealthy", down, len(endpoints)), Details: details}
+		}
+	}
+	return CheckResult{Status: CheckOK, Details: details}
+}
+
+// Check the node is registered in the contract
+func (n *OracleNode) checkRegistration(ctx context.Context) CheckResult {
+	isNode, err := n.contract.IsNode(&bind.CallOpts{Context: ctx}, n.address)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: fmt.Sprintf("failed to read isNode: %v", err)}
+	}
+	if !isNode {
+		return CheckResult{Status: CheckFail, Message: "node is not registered in the oracle"}
+	}
+	return CheckResult{Status: CheckOK}
+}
+
+// Check the account can still pay for gas, from the last poll of the balance monitor
+func (n *OracleNode) checkBalance() CheckResult {
+	status := n.balance.Status()
+	if status.Balance == nil {
+		if status.Err != nil {
+			return CheckResult{Status: CheckFail, Message: fmt.Sprintf("failed to read balance: %v", status.Err)}
+		}
+		return CheckResult{Status: CheckFail, Message: "balance not read yet"}
+	}
+
+	details := map[string]interface{}{
+		"balance_eth":      weiToEth(status.Balance),
+		"level":            status.Level,
+		"warn_balance_eth": n.config.WarnBalanceEth,
+		"min_balance_eth":  n.config.MinBalanceEth,
+		"checked_at":       status.CheckedAt,
+	}
+	if status.Err != nil {
+		details["error"] = status.Err.Error()
+	}
+	switch status.Level {
+	case BalanceCritical:
+		return CheckResult{Status: CheckFail, Message: "balance below min_balance_eth, submissions paused", Details: details}
+	case BalanceWarn:
+		return CheckResult{Status: CheckWarn, Message: "balance below warn_balance_eth, top up the account", Details: details}
+	}
+	return CheckResult{Status: CheckOK, Details: details}
+}
+
+// Check every coin completed a cycle within the last stale_intervals intervals
+func (n *OracleNode) checkSubmissions() CheckResult {
+	staleAfter := n.staleAfter()
+	details := make(map[string]interface{})
+	result := CheckResult{Status: CheckOK, Details: details}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, coin := range feedKeys(n.feeds) {
+		status := n.coinStatusLocked(coin)
+		coinDetails := map[string]interface{}{}
+		if !status.LastSubmitted.IsZero() {
+			coinDetails["last_submitted"] = status.LastSubmitted
+		}
+		if !status.LastCycle.IsZero() {
+			coinDetails["last_cycle"] = status.LastCycle
+			coinDetails["last_result"] = status.LastResult
+		}
+
+		switch {
+		case !status.LastSuccess.IsZero():
+			coinDetails["last_success"] = status.LastSuccess
+		case time.Since(n.startedAt) <= staleAfter:
+			coinDetails["pending"] = true
+		}
+
+		// Stale since the last successful cycle, or since start without one
+		lastSuccess := status.LastSuccess
+		if lastSuccess.IsZero() {
+			lastSuccess = n.startedAt
+		}
+		if time.Since(lastSuccess) > staleAfter {
+			result.Status = CheckFail
+			switch {
+			case status.LastResult == SkipLowBalance:
+				result.Message = fmt.Sprintf("submissions of %s paused, balance below min_balance_eth", coin)
+			case status.LastSuccess.IsZero():
+				result.Message = fmt.Sprintf("no successful cycle for %s since start", coin)
+			default:
+				result.Message = fmt.Sprintf("no successful cycle for %s in the last %s", coin, staleAfter)
+			}
+		}
+		details[coin] = coinDetails
+	}
+	return result
+}
+
+// Check enough price sources answered at the last fetch of every coin
+func (n *OracleNode) checkSources() CheckResult {
+	minSources := max(n.config.MinSources, 1)
+	details := make(map[string]interface{})
+	result := CheckResult{Status: CheckOK, Details: details}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, coin := range feedKeys(n.feeds) {
+		status := n.coinStatusLocked(coin)
+		if status.LastFetch.IsZero() {
+			details[coin] = map[string]interface{}{"pending": true}
+			continue
+		}
+		details[coin] = map[string]interface{}{"reachable": status.SourcesOK, "failed": status.SourcesFailed}
+
+		switch {
+		case status.SourcesOK < minSources:
+			result.Status = CheckFail
+			result.Message = fmt.Sprintf("only %d sources reachable for %s, need %d", status.SourcesOK, coin, minSources)
+		case len(status.SourcesFailed) > 0 && result.Status == CheckOK:
+			result.Status = CheckWarn
+			result.Message = fmt.Sprintf("some sources failed for %s", coin)
+		}
+	}
+	return result
+}
+
+// Serve a probe: 200 while no check fails, 503 otherwise
+func (n *OracleNode) probeHandler(run func(context.Context) map[string]CheckResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		defer cancel()
+
+		report := HealthReport{
+			Status:  CheckOK,
+			Node:    n.config.Name,
+			Address: n.address.Hex(),
+			Time:    time.Now().UTC(),
+			Checks:  run(ctx),
+		}
+		for _, check := range report.Checks {
+			if check.Status == CheckFail {
+				report.Status = CheckFail
+				break
+			}
+			if check.Status == CheckWarn {
+				report.Status = CheckWarn
+			}
+		}
+
+		code := http.StatusOK
+		if report.Status == CheckFail {
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(report)
+	}
+}
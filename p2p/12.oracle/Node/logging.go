@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Formats of log_format
+const (
+	LogFormatText = "text" // key=value pairs, easy to read in a terminal
+	LogFormatJSON = "json" // One JSON object per line, for log pipelines
+)
+
+// Parse a log_level: debug, info, warn or error
+func parseLogLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid log_level %q (expected debug, info, warn or error)", level)
+	}
+	return parsed, nil
+}
+
+// newLogger writes to stderr with the format and level of a config
+// The config is validated, an invalid level falls back to info
+func newLogger(cfg *Config) *slog.Logger {
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	options := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(cfg.LogFormat, LogFormatJSON) {
+		return slog.New(slog.NewJSONHandler(os.Stderr, options))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, options))
+}
+
+// Logger of one node: every record carries node_id and node so the
+// interleaved output of the nodes of a process can be filtered
+func nodeLogger(cfg *Config, nodeID int) *slog.Logger {
+	return newLogger(cfg).With("node_id", nodeID, "node", cfg.Name)
+}
+
+// Log an error and exit, for failures before the nodes start
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
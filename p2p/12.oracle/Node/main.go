@@ -2,155 +2,206 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/joho/godotenv"
 )
 
 type OracleNode struct {
-	client          *ethclient.Client
+	client          ChainBackend
 	contract        *Oracle
-	privateKey      *ecdsa.PrivateKey
+	contractABI     *abi.ABI
+	txManager       *TxManager
+	metrics         *Metrics
+	watcher         *Watcher
+	balance         *BalanceMonitor
+	store           *Store // History database, only opened by the run command
 	address         common.Address
 	config          *Config
 	contractAddress common.Address
-	nodeID          int
+	logger          *slog.Logger // Carries node_id, node and address
+	feeds           []Feed
+	sources         map[string][]PriceSource // Price sources by feed key
+	policy          SubmissionPolicy
+
+	mu         sync.Mutex
+	lastPrices map[string]*AggregatedPrice // Last aggregated price per coin
+	coinStatus map[string]*CoinStatus      // Last fetch and submission per coin, for health checks
+	lastTick   time.Time                   // Last iteration of the submission loop
+	startedAt  time.Time
+
+	stopTracker  context.CancelFunc
+	closeSigner  func() // Closes the connection to an external signer
+	closeClients func() // Closes the RPC connections opened by NewOracleNode
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, "Oracle Node is running")
-}
-
-type CoinPrice struct {
-	USD float64 `json:"usd"`
+// Close stops the receipt tracker, then the signer and RPC connections of the node
+func (n *OracleNode) Close() {
+	n.stopTracker()
+	n.closeSigner()
+	if n.closeClients != nil {
+		n.closeClients()
+	}
 }
 
-func fetchPrice(coinID, apiKey string) (float64, error) {
-	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", coinID)
-	client := http.Client{Timeout: 10 * time.Second}
-
-	req, err := http.NewRequest("GET", url, nil)
+// Initialize the Oracle Node, connected to the RPC endpoints of its config
+func NewOracleNode(ctx context.Context, config *Config, nodeID int) (*OracleNode, error) {
+	// Connect to the Ethereum nodes, failing over between the configured endpoints
+	logger := nodeLogger(config, nodeID)
+	healthCheckInterval := time.Duration(config.RPCHealthCheckInterval) * time.Second
+	client, err := DialFailover(ctx, config.RPCEndpoints(), config.RPCRetries, healthCheckInterval, logger.With("rpc", "http"))
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
 	}
+	closeClients := client.Close
 
-	if apiKey != "" {
-		req.Header.Set("x-cg-demo-api-key", apiKey)
+	// Events are watched over dedicated websocket connections when some are configured
+	var watchClient ChainBackend = client
+	if wsURLs := config.WSEndpoints(); len(wsURLs) > 0 {
+		wsClient, err := DialFailover(ctx, wsURLs, config.RPCRetries, healthCheckInterval, logger.With("rpc", "ws"))
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to websocket RPC: %w", err)
+		}
+		watchClient = wsClient
+		closeClients = func() {
+			wsClient.Close()
+			client.Close()
+		}
 	}
 
-	resp, err := client.Do(req)
+	node, err := NewOracleNodeWithBackend(ctx, config, nodeID, client, watchClient)
 	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
+		closeClients()
+		return nil, err
 	}
+	node.closeClients = closeClients
+	return node, nil
+}
 
-	var result map[string]CoinPrice
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, err
+// NewOracleNodeWithBackend initializes a node on existing chain connections, events
+// are read from watchBackend which may be backend itself
+// The connections are left open when the node is closed
+func NewOracleNodeWithBackend(ctx context.Context, config *Config, nodeID int, backend, watchBackend ChainBackend) (*OracleNode, error) {
+	feeds, err := config.ParseFeeds()
+	if err != nil {
+		return nil, err
 	}
 
-	if priceData, ok := result[coinID]; ok {
-		return priceData.USD, nil
-	}
-	return 0, fmt.Errorf("coin not found")
-}
+	contractAddress := common.HexToAddress(config.ContractAddress)
 
-func priceHandler(w http.ResponseWriter, r *http.Request) {
-	coin := r.URL.Query().Get("coin")
-	if coin == "" {
-		http.Error(w, "Missing 'coin' query parameter", http.StatusBadRequest)
-		return
+	// Create contract instance
+	contract, err := NewOracle(contractAddress, backend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate contract: %w", err)
 	}
 
-	price, err := fetchPrice(coin, "")
+	contractABI, err := OracleMetaData.GetAbi()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fetch price: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to parse contract ABI: %w", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"coin":     coin,
-		"price":    price,
-		"currency": "usd",
-	})
-}
-
-// Convert float64 price to big.Int with 8 decimals precision
-// Example: 50000.25 USD -> 5000025000000 (50000.25 * 10^8)
-func floatToBigInt(price float64) *big.Int {
-	// Multiply by 10^8 to preserve 8 decimal places
-	scaled := price * 1e8
-	bigFloat := new(big.Float).SetFloat64(scaled)
-	bigInt := new(big.Int)
-	bigFloat.Int(bigInt)
-	return bigInt
-}
-
-// Initialize the Oracle Node
-func NewOracleNode(config *Config, nodeID int) (*OracleNode, error) {
-	// Connect to Ethereum node
-	client, err := ethclient.Dial(config.RPCURL)
+	// Get chain ID
+	chainID, err := backend.ChainID(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum node: %v", err)
+		return nil, fmt.Errorf("failed to get chain ID: %w", err)
 	}
 
-	// Load private key
-	privateKey, err := crypto.HexToECDSA(config.PrivateKey)
+	// Load the node key (raw key, keystore or external signer)
+	address, signer, closeSigner, err := loadSigner(config, chainID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid private key: %v", err)
+		return nil, err
 	}
 
-	publicKey := privateKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("error casting public key to ECDSA")
+	// Create the transaction manager of this key
+	gasSettings := GasSettings{
+		GasLimitMarginPercent: config.GasLimitMarginPercent,
+		StuckTxBlocks:         config.StuckTxBlocks,
+		FeeBumpPercent:        config.FeeBumpPercent,
 	}
+	if config.MaxFeeCapGwei > 0 {
+		gasSettings.MaxFeeCap = new(big.Int).Mul(big.NewInt(config.MaxFeeCapGwei), big.NewInt(params.GWei))
+	}
+	logger := nodeLogger(config, nodeID).With("address", address.Hex())
+	txManager := NewTxManager(backend, address, chainID, signer, gasSettings, logger)
 
-	address := crypto.PubkeyToAddress(*publicKeyECDSA)
-	contractAddress := common.HexToAddress(config.ContractAddress)
+	metrics := NewMetrics(config.Name, txManager)
 
-	// Create contract instance
-	contract, err := NewOracle(contractAddress, client)
+	// Build the price sources of each feed
+	sources := make(map[string][]PriceSource)
+	for _, feed := range feeds {
+		coin := feed.Key
+		for _, sourceConfig := range config.SourcesFor(coin) {
+			if sourceConfig.Type == "coingecko" && sourceConfig.APIKey == "" {
+				sourceConfig.APIKey = config.CoingeckoApiKey
+			}
+			source, err := NewPriceSource(sourceConfig)
+			if err != nil {
+				closeSigner()
+				return nil, fmt.Errorf("invalid price source for %s: %w", coin, err)
+			}
+			// Requests go through the fetcher shared with the other nodes of the process
+			sources[coin] = append(sources[coin], sharedFetcher.Wrap(source, sourceConfig, metrics))
+		}
+	}
+
+	watcher, err := NewWatcher(watchBackend, contractAddress, address, feeds,
+		time.Duration(config.EventPollInterval)*time.Second, logger, metrics)
 	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate contract: %v", err)
+		closeSigner()
+		return nil, err
 	}
 
-	log.Printf("[Node %d] Oracle Node initialized", nodeID)
-	log.Printf("[Node %d]   Address: %s", nodeID, address.Hex())
-	log.Printf("[Node %d]   Contract: %s", nodeID, contractAddress.Hex())
-	log.Printf("[Node %d]   RPC: %s", nodeID, config.RPCURL)
+	// Receipts are tracked until the node is closed, after in-flight submissions are drained
+	trackerCtx, stopTracker := context.WithCancel(context.Background())
+	go txManager.Start(trackerCtx)
+
+	logger.Info("Oracle node initialized", "contract", contractAddress.Hex(), "rpc", config.RPCURL, "chain_id", chainID.String())
 
 	node := &OracleNode{
-		client:          client,
+		client:          backend,
 		contract:        contract,
-		privateKey:      privateKey,
+		contractABI:     contractABI,
+		txManager:       txManager,
+		metrics:         metrics,
+		watcher:         watcher,
+		balance:         NewBalanceMonitor(backend, address, config, logger, metrics),
 		address:         address,
 		config:          config,
 		contractAddress: contractAddress,
-		nodeID:          nodeID,
-	}
-
-	// Check if node is already registered
-	if err := node.EnsureRegistered(context.Background()); err != nil {
-		return nil, fmt.Errorf("failed to register node: %v", err)
-	}
+		logger:          logger,
+		feeds:           feeds,
+		sources:         sources,
+		policy: SubmissionPolicy{
+			DeviationBps: int64(config.DeviationThresholdBps),
+			Heartbeat:    time.Duration(config.HeartbeatInterval) * time.Second,
+		},
+		lastPrices:  make(map[string]*AggregatedPrice),
+		coinStatus:  make(map[string]*CoinStatus),
+		startedAt:   time.Now(),
+		stopTracker: stopTracker,
+		closeSigner: closeSigner,
+	}
+
+	node.updateBalance(ctx)
 
 	return node, nil
 }
@@ -158,264 +209,528 @@ func NewOracleNode(config *Config, nodeID int) (*OracleNode, error) {
 // EnsureRegistered checks if the node is registered and registers it if not
 func (n *OracleNode) EnsureRegistered(ctx context.Context) error {
 	// Check if already registered
-	isRegistered, err := n.contract.OracleCaller.IsNode(&bind.CallOpts{}, n.address)
+	isRegistered, err := n.contract.OracleCaller.IsNode(&bind.CallOpts{Context: ctx}, n.address)
 	if err != nil {
-		return fmt.Errorf("failed to check if node is registered: %v", err)
+		return fmt.Errorf("failed to check if node is registered: %w", err)
 	}
 
 	if isRegistered {
-		log.Printf("[Node %d] ✓ Already registered in Oracle", n.nodeID)
+		n.logger.Info("Already registered in Oracle")
 		return nil
 	}
 
-	log.Printf("[Node %d] ⚠ Not registered. Requesting to join Oracle...", n.nodeID)
+	n.logger.Warn("Not registered, requesting to join Oracle")
 
-	// Get the suggested gas price
-	gasPrice, err := n.client.SuggestGasPrice(ctx)
+	// Call addNode() to register
+	data, err := n.contractABI.Pack("addNode")
 	if err != nil {
-		return fmt.Errorf("failed to suggest gas price: %v", err)
+		return fmt.Errorf("failed to encode addNode call: %w", err)
 	}
-
-	// Get nonce
-	nonce, err := n.client.PendingNonceAt(ctx, n.address)
+	pending, err := n.txManager.Send(ctx, "addNode", n.contractAddress, data)
 	if err != nil {
-		return fmt.Errorf("failed to get nonce: %v", err)
+		return fmt.Errorf("failed to register node: %w", err)
 	}
+	tx := pending.Tx()
 
-	// Get chain ID
-	chainID, err := n.client.ChainID(ctx)
+	n.logger.Info("Registration sent, waiting for confirmation", "tx_hash", tx.Hash().Hex())
+
+	// Wait for transaction to be mined
+	receipt, err := pending.Wait(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get chain ID: %v", err)
+		return fmt.Errorf("registration transaction failed: %w", err)
 	}
 
-	// Create transaction options
-	auth, err := bind.NewKeyedTransactorWithChainID(n.privateKey, chainID)
-	if err != nil {
-		return fmt.Errorf("failed to create transactor: %v", err)
+	n.metrics.ObserveReceipt(receipt)
+
+	if receipt.Status == 1 {
+		n.logger.Info("Registered", "tx_hash", receipt.TxHash.Hex(),
+			"block", receipt.BlockNumber.Uint64(), "gas", receipt.GasUsed)
+	} else {
+		return fmt.Errorf("registration transaction %s: %w", receipt.TxHash.Hex(), n.txManager.RevertError(ctx, pending.Tx(), receipt))
 	}
 
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)
-	auth.GasLimit = uint64(100000)
-	auth.GasPrice = gasPrice
+	return nil
+}
 
-	// Call addNode() to register
-	tx, err := n.contract.OracleTransactor.AddNode(auth)
+// Deregister removes the node from the oracle so it no longer counts in the quorum
+func (n *OracleNode) Deregister(ctx context.Context) error {
+	isRegistered, err := n.contract.OracleCaller.IsNode(&bind.CallOpts{Context: ctx}, n.address)
 	if err != nil {
-		return fmt.Errorf("failed to register node: %v", err)
+		return fmt.Errorf("failed to check if node is registered: %w", err)
 	}
 
-	log.Printf("[Node %d] Registration tx: %s", n.nodeID, tx.Hash().Hex())
-	log.Printf("[Node %d] Waiting for confirmation...", n.nodeID)
+	if !isRegistered {
+		n.logger.Info("Not registered in Oracle, nothing to do")
+		return nil
+	}
 
-	// Wait for transaction to be mined
-	receipt, err := bind.WaitMined(ctx, n.client, tx)
+	n.logger.Info("Leaving Oracle")
+
+	data, err := n.contractABI.Pack("removeNode")
+	if err != nil {
+		return fmt.Errorf("failed to encode removeNode call: %w", err)
+	}
+	pending, err := n.txManager.Send(ctx, "removeNode", n.contractAddress, data)
 	if err != nil {
-		return fmt.Errorf("registration transaction failed: %v", err)
+		return fmt.Errorf("failed to deregister node: %w", err)
 	}
 
-	if receipt.Status == 1 {
-		log.Printf("[Node %d] ✓ Successfully registered! Block: %d, Gas: %d",
-			n.nodeID, receipt.BlockNumber.Uint64(), receipt.GasUsed)
-	} else {
-		return fmt.Errorf("registration transaction reverted")
+	n.logger.Info("Deregistration sent, waiting for confirmation", "tx_hash", pending.Tx().Hash().Hex())
+
+	receipt, err := pending.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("deregistration transaction failed: %w", err)
 	}
 
+	n.metrics.ObserveReceipt(receipt)
+
+	if receipt.Status != 1 {
+		return fmt.Errorf("deregistration transaction %s: %w", receipt.TxHash.Hex(), n.txManager.RevertError(ctx, pending.Tx(), receipt))
+	}
+	n.logger.Info("Deregistered", "tx_hash", receipt.TxHash.Hex(),
+		"block", receipt.BlockNumber.Uint64(), "gas", receipt.GasUsed)
 	return nil
 }
 
-// Submit price for a specific coin
-func (n *OracleNode) SubmitPrice(ctx context.Context, coin string) error {
-	// Fetch price from CoinGecko
-	price, err := fetchPrice(coin, n.config.CoingeckoApiKey)
-	if err != nil {
-		return fmt.Errorf("failed to fetch price for %s: %v", coin, err)
-	}
+// Keep the history of the node in store, from the submissions and the watcher
+func (n *OracleNode) attachStore(store *Store) {
+	n.store = store
+	n.watcher.store = store
+}
 
-	// Convert to big.Int with 8 decimals
-	priceInt := floatToBigInt(price)
+// Refresh the balance of the node account, pausing submissions when it is critical
+func (n *OracleNode) updateBalance(ctx context.Context) {
+	if err := n.balance.Poll(ctx); err != nil {
+		n.logger.Warn("Failed to get balance", "error", err)
+	}
+}
 
-	log.Printf("[Node %d] Fetched %s: $%.2f", n.nodeID, coin, price)
+// Submit price for a specific feed, stored on-chain under the feed key
+func (n *OracleNode) SubmitPrice(ctx context.Context, feed Feed) error {
+	coin := feed.Key
+	logger := n.logger.With("coin", coin)
 
-	// Get the suggested gas price
-	gasPrice, err := n.client.SuggestGasPrice(ctx)
+	// Fetch price from all configured sources and aggregate them
+	aggregated, err := n.aggregatePrice(ctx, feed)
 	if err != nil {
-		return fmt.Errorf("failed to suggest gas price: %v", err)
+		return fmt.Errorf("failed to fetch price for %s: %w", coin, err)
+	}
+
+	// Convert to the fixed point of the feed
+	priceInt := feed.ToChain(aggregated.Price)
+	n.store.Record(n.logger, HistoryRecord{
+		Kind:     RecordPrice,
+		Coin:     coin,
+		Price:    feed.Format(priceInt),
+		Sources:  aggregated.Sources,
+		Rejected: aggregated.Rejected,
+		Failed:   aggregated.Failed,
+	})
+
+	logger.Info("Fetched price", "price", feed.Format(priceInt), "currency", feed.Quote,
+		"sources", strings.Join(aggregated.Sources, ","))
+	if len(aggregated.Rejected) > 0 {
+		logger.Warn("Dropped outlier prices", "sources", strings.Join(aggregated.Rejected, ","))
 	}
 
-	// Get nonce
-	nonce, err := n.client.PendingNonceAt(ctx, n.address)
+	// Only spend gas when the price moved enough or the on-chain value is stale
+	onChain, err := n.onChainPrice(ctx, coin)
 	if err != nil {
-		return fmt.Errorf("failed to get nonce: %v", err)
+		return fmt.Errorf("failed to read on-chain price for %s: %w", coin, err)
+	}
+	if !onChain.LastUpdatedAt.IsZero() {
+		n.metrics.SetOnChainUpdatedAt(coin, onChain.LastUpdatedAt)
+	}
+	// The contract accepts one submission per node and round, wait for the next one
+	if onChain.Submitted {
+		logger.Info("Skipping submission, already submitted for this round, waiting for quorum",
+			"round_id", onChain.RoundID.String(), "submissions", onChain.Submissions.String())
+		n.metrics.submissionsSkipped.WithLabelValues(coin, SkipAlreadySubmitted).Inc()
+		n.recordCycle(coin, SkipAlreadySubmitted)
+		return nil
+	}
+	submit, reason := n.policy.ShouldSubmit(priceInt, onChain, time.Now())
+	if !submit {
+		logger.Info("Skipping submission", "reason", reason)
+		n.metrics.submissionsSkipped.WithLabelValues(coin, SkipInSync).Inc()
+		n.recordCycle(coin, SkipInSync)
+		return nil
 	}
+	// A transaction would fail for lack of gas, wait for the account to be topped up
+	if n.balance.Paused() {
+		logger.Warn("Skipping submission, balance below the critical level", "reason", reason,
+			"balance_eth", weiToEth(n.balance.Status().Balance), "min_balance_eth", n.config.MinBalanceEth)
+		n.metrics.submissionsSkipped.WithLabelValues(coin, SkipLowBalance).Inc()
+		n.recordCycle(coin, SkipLowBalance)
+		return nil
+	}
+	logger.Info("Submitting price", "reason", reason, "round_id", onChain.RoundID.String())
 
-	// Get chain ID
-	chainID, err := n.client.ChainID(ctx)
+	// Submit price to contract
+	data, err := n.contractABI.Pack("submitPrice", coin, priceInt)
 	if err != nil {
-		return fmt.Errorf("failed to get chain ID: %v", err)
+		return fmt.Errorf("failed to encode submitPrice call: %w", err)
 	}
-
-	// Create transaction options
-	auth, err := bind.NewKeyedTransactorWithChainID(n.privateKey, chainID)
+	submission := HistoryRecord{
+		Kind:    RecordSubmission,
+		Coin:    coin,
+		Price:   feed.Format(priceInt),
+		RoundID: onChain.RoundID.String(),
+	}
+	pending, err := n.txManager.Send(ctx, "submitPrice "+coin, n.contractAddress, data)
 	if err != nil {
-		return fmt.Errorf("failed to create transactor: %v", err)
+		submission.Status, submission.Error = "failed", err.Error()
+		n.recordSubmission(submission)
+		return fmt.Errorf("failed to submit price: %w", err)
 	}
+	n.metrics.submissionsAttempted.WithLabelValues(coin).Inc()
+	tx := pending.Tx()
 
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)
-	auth.GasLimit = uint64(300000)
-	auth.GasPrice = gasPrice
+	logger.Info("Submission sent", "tx_hash", tx.Hash().Hex(), "round_id", submission.RoundID)
 
-	// Submit price to contract
-	tx, err := n.contract.OracleTransactor.SubmitPrice(auth, coin, priceInt)
+	// Wait for transaction to be mined, the receipt is tracked by the transaction manager
+	submission.TxHash = tx.Hash().Hex()
+	receipt, err := pending.Wait(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to submit price: %v", err)
+		submission.Status, submission.Error = "failed", err.Error()
+		n.recordSubmission(submission)
+		return fmt.Errorf("transaction failed: %w", err)
 	}
 
-	log.Printf("[Node %d] Submitting %s tx: %s", n.nodeID, coin, tx.Hash().Hex())
+	n.metrics.ObserveReceipt(receipt)
+	n.updateBalance(ctx)
 
-	// Wait for transaction to be mined
-	receipt, err := bind.WaitMined(ctx, n.client, tx)
-	if err != nil {
-		return fmt.Errorf("transaction failed: %v", err)
+	// The receipt may come from a fee-bumped replacement
+	submission.TxHash = receipt.TxHash.Hex()
+	submission.GasUsed = receipt.GasUsed
+	submission.Block = receipt.BlockNumber.Uint64()
+	submission.Status = "success"
+	var revert *RevertError
+	if receipt.Status != 1 {
+		revert = n.txManager.RevertError(ctx, pending.Tx(), receipt)
+		submission.Status, submission.Error = "reverted", revert.Error()
 	}
+	n.recordSubmission(submission)
 
 	if receipt.Status == 1 {
-		log.Printf("[Node %d] ✓ %s submitted! Block: %d, Gas: %d",
-			n.nodeID, coin, receipt.BlockNumber.Uint64(), receipt.GasUsed)
+		n.metrics.submissionsSucceeded.WithLabelValues(coin).Inc()
+		price, _ := aggregated.Price.Float64()
+		n.metrics.lastSubmittedPrice.WithLabelValues(coin).Set(price)
+		n.recordCycle(coin, CycleSubmitted)
+		logger.Info("Price submitted", "tx_hash", submission.TxHash, "round_id", submission.RoundID,
+			"block", submission.Block, "gas", submission.GasUsed)
 	} else {
-		return fmt.Errorf("transaction reverted")
+		n.metrics.submissionsReverted.WithLabelValues(coin).Inc()
+		return fmt.Errorf("transaction %s: %w", submission.TxHash, revert)
 	}
 
 	return nil
 }
 
-// Start automatic price submission loop
+// Start automatic price submission loop, until ctx is done
+// In-flight submissions are then given ShutdownTimeout seconds to complete
 func (n *OracleNode) StartPriceSubmissionLoop(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(n.config.SubmissionInterval) * time.Second)
 	defer ticker.Stop()
 
-	log.Printf("[Node %d] Starting submission loop (interval: %ds)", n.nodeID, n.config.SubmissionInterval)
-	log.Printf("[Node %d] Tracking coins: %v", n.nodeID, n.config.Coins)
-
-	// Removed staggered delay to allow simultaneous submission
-	// initialDelay := time.Duration(n.nodeID*8) * time.Second ...
-
-	// Submit prices immediately on start
-	for _, coin := range n.config.Coins {
-		if err := n.SubmitPrice(ctx, coin); err != nil {
-			log.Printf("[Node %d] Error submitting %s: %v", n.nodeID, coin, err)
+	// Submissions run under their own context so a shutdown does not cut a transaction in half
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	var (
+		drainMu    sync.Mutex
+		drainTimer *time.Timer
+		drained    bool // Once set the drain deadline is not armed anymore
+	)
+	stopDrain := context.AfterFunc(ctx, func() {
+		drainMu.Lock()
+		defer drainMu.Unlock()
+		if !drained {
+			drainTimer = time.AfterFunc(time.Duration(n.config.ShutdownTimeout)*time.Second, cancelWork)
 		}
-		// Add delay between coins to avoid rate limits (1 second)
-		time.Sleep(1 * time.Second)
+	})
+	defer func() {
+		stopDrain()
+		drainMu.Lock()
+		defer drainMu.Unlock()
+		drained = true
+		if drainTimer != nil {
+			drainTimer.Stop()
+		}
+	}()
+
+	n.logger.Info("Starting submission loop", "interval_seconds", n.config.SubmissionInterval,
+		"deviation_bps", n.config.DeviationThresholdBps, "heartbeat_seconds", n.config.HeartbeatInterval,
+		"feeds", strings.Join(feedKeys(n.feeds), ","))
+
+	// Cycles run apart from the loop so it keeps ticking, which liveness reads, while
+	// a cycle waits on the chain. A tick arriving during a cycle does not start another
+	var (
+		cycles  sync.WaitGroup
+		running atomic.Bool
+	)
+	defer cycles.Wait()
+	startCycle := func() {
+		n.mu.Lock()
+		n.lastTick = time.Now()
+		n.mu.Unlock()
+		if !running.CompareAndSwap(false, true) {
+			n.logger.Info("Previous cycle still running, skipping this tick")
+			return
+		}
+		cycles.Add(1)
+		go func() {
+			defer cycles.Done()
+			defer running.Store(false)
+			n.submitAll(ctx, workCtx)
+		}()
 	}
 
+	// Submit prices immediately on start
+	startCycle()
+
 	// Then submit on interval
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Node %d] Stopping submission loop", n.nodeID)
+			n.logger.Info("Stopping submission loop")
 			return
 		case <-ticker.C:
-			for _, coin := range n.config.Coins {
-				if err := n.SubmitPrice(ctx, coin); err != nil {
-					log.Printf("[Node %d] Error submitting %s: %v", n.nodeID, coin, err)
-				}
-				// Add delay between coins to avoid rate limits (1 second)
-				time.Sleep(1 * time.Second)
-			}
+			startCycle()
 		}
 	}
 }
 
-func main() {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Note: No .env file found, using environment variables")
+// Submit every tracked feed without waiting on each other's transactions
+// No new feed is started once ctx is done, started ones run under workCtx
+func (n *OracleNode) submitAll(ctx, workCtx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// One request per provider for all feeds, then each feed reads its quotes from the cache
+	n.prefetchPrices(workCtx)
+
+	// Rate limits are enforced by the shared fetcher, feeds are not staggered
+	for _, feed := range n.feeds {
+		if ctx.Err() != nil {
+			return
+		}
+
+		wg.Add(1)
+		go func(feed Feed) {
+			defer wg.Done()
+			err := n.SubmitPrice(workCtx, feed)
+			if err == nil {
+				return
+			}
+			kind := errorKind(err)
+			n.metrics.submissionErrors.WithLabelValues(feed.Key, kind).Inc()
+			switch {
+			case errors.Is(err, ErrRPCDown), errors.Is(err, ErrUnderpriced), errors.Is(err, ErrNonceTooLow):
+				// Transient, the next tick tries again with a fresh nonce and fees
+				n.logger.Warn("Submission failed, retrying next tick", "coin", feed.Key, "kind", kind, "error", err)
+			default:
+				// Reverts and missing funds need a change of state or an operator
+				n.logger.Error("Submission failed", "coin", feed.Key, "kind", kind, "error", err)
+			}
+			if errors.Is(err, ErrInsufficientFunds) {
+				// Pause right away when the balance is critical instead of at the next poll
+				n.updateBalance(workCtx)
+			}
+		}(feed)
 	}
+}
 
-	config := LoadConfig()
+// Run one node until ctx is done: HTTP server, submission loop, then a clean shutdown
+// Returns an error only if the node could not start
+func runNode(ctx context.Context, id int, cfg *Config) error {
+	logger := nodeLogger(cfg, id)
+	logger.Info("Initializing node")
 
-	// Anvil default private keys (first 10 accounts)
-	anvilPrivateKeys := []string{
-		"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", // Account 0
-		"59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d", // Account 1
-		"5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a", // Account 2
-		"7c852118294e51e653712a81e05800f419141751be58f605c371e15141b007a6", // Account 3
-		"47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a", // Account 4
-		"8b3a350cf5c34c9194ca85829a2df0ec3153be0318b5e2d3348e872092edffba", // Account 5
-		"92db14e403b83dfe3df233f83dfa3a0d7096f21ca9b0d6d6b8d88b2b4ec1564e", // Account 6
-		"4bbbf85ce3377467afe5d46f804f221813b2bb87f24d81f60f1fcdbf7cbf4356", // Account 7
-		"dbda1821b80551c9d65939329250298aa3472ba22feea921c0cf5d620ea67b97", // Account 8
-		"2a871d0798f97d79848a013d4936a73bf4cc922c825d33c1cf7073dff6d409c6", // Account 9
+	// Initialize Oracle Node
+	oracleNode, err := NewOracleNode(ctx, cfg, id)
+	if err != nil {
+		return err
 	}
+	defer oracleNode.Close()
 
-	ctx := context.Background()
+	// Check if node is already registered
+	if err := oracleNode.EnsureRegistered(ctx); err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
+	}
 
-	log.Printf("========================================")
-	log.Printf("========================================")
-	log.Printf("Starting 4 Oracle Nodes (Sharing 3 API Keys)")
-	log.Printf("========================================\n")
+	// Open the history database, closed once the node stopped using it
+	store, err := OpenStore(filepath.Join(cfg.DataDir, cfg.Name+".db"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	oracleNode.attachStore(store)
+
+	// Start HTTP server
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", oracleNode.probeHandler(oracleNode.livenessChecks))
+	mux.Handle("/health", oracleNode.probeHandler(oracleNode.livenessChecks))
+	mux.Handle("/readyz", oracleNode.probeHandler(oracleNode.readinessChecks))
+	mux.HandleFunc("/price", oracleNode.priceHandler)
+	mux.HandleFunc("/onchain", oracleNode.onChainHandler)
+	mux.HandleFunc("/submission", oracleNode.submissionHandler)
+	mux.HandleFunc("/nodes", oracleNode.nodesHandler)
+	mux.Handle("/metrics", oracleNode.metrics.Handler())
+	mux.HandleFunc("/history", store.historyHandler)
+	server := &http.Server{Addr: cfg.HTTPPort, Handler: mux}
+
+	listener, err := net.Listen("tcp", cfg.HTTPPort)
+	if err != nil {
+		return fmt.Errorf("HTTP server error: %w", err)
+	}
+	oracleNode.logger.Info("Starting HTTP server", "listen", cfg.HTTPPort)
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			oracleNode.logger.Error("HTTP server error", "error", err)
+		}
+	}()
+
+	// Follow finalized rounds and the balance of the node, both stopped before the
+	// store and the node are closed
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		oracleNode.watcher.Run(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		oracleNode.balance.Run(ctx)
+	}()
+
+	// Start price submission loop, returns once in-flight submissions are drained
+	oracleNode.StartPriceSubmissionLoop(ctx)
+	background.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		oracleNode.logger.Error("HTTP server shutdown error", "error", err)
+	}
+	oracleNode.logger.Info("Stopped")
+	return nil
+}
 
-	// API Key for CoinGecko
-	apiKey := os.Getenv("COINGECKO_API_KEY")
-	if apiKey == "" {
-		log.Printf("⚠️  WARNING: COINGECKO_API_KEY not set, requests will use free tier")
-	} else {
-		log.Printf("✅ CoinGecko API Key loaded (length: %d)", len(apiKey))
-	}
-
-	// Launch 4 nodes concurrently
-	for i := 0; i < 4; i++ {
-		nodeID := i
-		privateKey := anvilPrivateKeys[i]
-		httpPort := fmt.Sprintf(":808%d", i)
-
-		// Create a config for each node
-		nodeConfig := &Config{
-			RPCURL:             config.RPCURL,
-			ContractAddress:    config.ContractAddress,
-			PrivateKey:         privateKey,
-			Coins:              config.Coins,
-			SubmissionInterval: config.SubmissionInterval,
-			HTTPPort:           httpPort,
-			CoingeckoApiKey:    apiKey,
+// Run every node until Ctrl+C, returns false if some failed to start
+func runAll(ctx context.Context, configs []*Config, configPath string) bool {
+	slog.Info("Starting oracle nodes", "nodes", len(configs), "config", configPath)
+
+	for _, cfg := range configs {
+		if cfg.CoingeckoApiKey == "" {
+			nodeLogger(cfg, cfg.ID).Warn("No CoinGecko API key, requests will use the free tier")
 		}
+	}
 
-		// Launch each node in a goroutine
+	// Launch the nodes concurrently
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+	for _, nodeConfig := range configs {
+		wg.Add(1)
 		go func(id int, cfg *Config) {
-			log.Printf("\n[Node %d] Initializing...", id)
-
-			// Initialize Oracle Node
-			oracleNode, err := NewOracleNode(cfg, id)
-			if err != nil {
-				log.Printf("[Node %d] Failed to initialize: %v", id, err)
-				return
+			defer wg.Done()
+			if err := runNode(ctx, id, cfg); err != nil {
+				nodeLogger(cfg, id).Error("Failed to start", "error", err)
+				failed.Store(true)
 			}
+		}(nodeConfig.ID, nodeConfig)
+	}
+
+	for _, cfg := range configs {
+		slog.Info("Node launched", "node_id", cfg.ID, "node", cfg.Name, "http", cfg.HTTPPort)
+	}
+	slog.Info("Press Ctrl+C to stop all nodes")
+
+	// Wait for every node to stop, then cancel the price requests still retrying
+	wg.Wait()
+	sharedFetcher.Close()
+
+	if failed.Load() {
+		slog.Error("Some nodes failed to start")
+		return false
+	}
+	slog.Info("All nodes stopped")
+	return true
+}
+
+func main() {
+	// The first argument may name a command, running the nodes is the default
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if _, ok := commands[command]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		printUsage()
+		os.Exit(2)
+	}
 
-			// Start HTTP server
-			go func() {
-				mux := http.NewServeMux()
-				mux.HandleFunc("/health", healthHandler)
-				mux.HandleFunc("/price", priceHandler)
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = printUsage
+	configPath := flags.String("config", "config.yaml", "path to the nodes configuration file")
+	onlyNode := flags.String("node", "", "only act on the node with this name (one node per process)")
+	var newKey KeyFlags
+	if command == "rotate-key" {
+		newKey.Register(flags)
+	}
+	flags.Parse(args)
 
-				log.Printf("[Node %d] Starting HTTP server on %s", id, cfg.HTTPPort)
-				if err := http.ListenAndServe(cfg.HTTPPort, mux); err != nil {
-					log.Printf("[Node %d] HTTP server error: %v", id, err)
-				}
-			}()
+	// Load .env file if it exists, its variables can be used in the config file
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No .env file found, using environment variables")
+	}
 
-			// Start price submission loop
-			oracleNode.StartPriceSubmissionLoop(ctx)
-		}(nodeID, nodeConfig)
+	configs, err := LoadConfig(*configPath)
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+
+	if *onlyNode != "" {
+		var selected []*Config
+		for _, cfg := range configs {
+			if cfg.Name == *onlyNode {
+				selected = append(selected, cfg)
+			}
+		}
+		if len(selected) == 0 {
+			fatal("No node with this name", "node", *onlyNode, "config", *configPath)
+		}
+		configs = selected
 	}
 
-	// Keep main thread alive
-	log.Printf("\n========================================")
-	log.Printf("All 4 nodes launched successfully!")
-	log.Printf("HTTP Ports: 8080-8083")
-	log.Printf("Press Ctrl+C to stop all nodes")
-	log.Printf("========================================\n")
+	// Messages of the process itself use the log settings of the first node
+	slog.SetDefault(newLogger(configs[0]))
+
+	// Ctrl+C or SIGTERM cancel the root context and start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	select {} // Block forever
+	switch command {
+	case "run":
+		if !runAll(ctx, configs, *configPath) {
+			os.Exit(1)
+		}
+		return
+	case "register":
+		err = forEachNode(ctx, configs, func(n *OracleNode) error { return n.EnsureRegistered(ctx) })
+	case "deregister":
+		err = forEachNode(ctx, configs, func(n *OracleNode) error { return n.Deregister(ctx) })
+	case "status":
+		err = printStatus(ctx, configs)
+	case "rotate-key":
+		if len(configs) != 1 {
+			fatal("rotate-key needs -node to select the node whose key is replaced")
+		}
+		err = rotateKey(ctx, configs[0], newKey)
+	}
+	if err != nil {
+		fatal("Command failed", "command", command, "error", err)
+	}
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reasons of oracle_submissions_skipped_total
+const (
+	SkipAlreadySubmitted = "already_submitted" // This node already submitted for the current round
+	SkipInSync           = "in_sync"           // Deviation below threshold and heartbeat not expired
+	SkipLowBalance       = "low_balance"       // Submissions paused, balance below min_balance_eth
+)
+
+// Metrics are the Prometheus series of one node, served on its /metrics endpoint
+type Metrics struct {
+	registry *prometheus.Registry
+
+	submissionsAttempted *prometheus.CounterVec
+	submissionsSucceeded *prometheus.CounterVec
+	submissionsReverted  *prometheus.CounterVec
+	submissionsSkipped   *prometheus.CounterVec
+	submissionErrors     *prometheus.CounterVec
+	fetchDuration        *prometheus.HistogramVec
+	fetchErrors          *prometheus.CounterVec
+	gasUsed              prometheus.Counter
+	feesSpent            prometheus.Counter
+	walletBalance        prometheus.Gauge
+	balanceLevel         prometheus.Gauge
+	lastSubmittedPrice   *prometheus.GaugeVec
+	onChainUpdatedAt     *prometheus.GaugeVec
+	roundsMissed         *prometheus.CounterVec
+}
+
+// NewMetrics registers the series of a node, every one labelled with its name
+func NewMetrics(nodeName string, txManager *TxManager) *Metrics {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	reg := prometheus.WrapRegistererWith(prometheus.Labels{"node": nodeName}, registry)
+
+	m := &Metrics{
+		registry: registry,
+		submissionsAttempted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_submissions_attempted_total",
+			Help: "Price submission transactions sent, per coin.",
+		}, []string{"coin"}),
+		submissionsSucceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_submissions_succeeded_total",
+			Help: "Price submission transactions mined successfully, per coin.",
+		}, []string{"coin"}),
+		submissionsReverted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_submissions_reverted_total",
+			Help: "Price submission transactions mined but reverted, per coin.",
+		}, []string{"coin"}),
+		submissionsSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_submissions_skipped_total",
+			Help: "Price checks that did not lead to a transaction, per coin and reason.",
+		}, []string{"coin", "reason"}),
+		submissionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_submission_errors_total",
+			Help: "Failed price checks and submissions, per coin and error kind.",
+		}, []string{"coin", "kind"}),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "oracle_source_fetch_duration_seconds",
+			Help:    "Latency of price requests, per source.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 9), // 50ms to 12.8s
+		}, []string{"source"}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_source_fetch_errors_total",
+			Help: "Failed price requests, per source.",
+		}, []string{"source"}),
+		gasUsed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "oracle_gas_used_total",
+			Help: "Gas used by mined transactions.",
+		}),
+		feesSpent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "oracle_fees_spent_eth_total",
+			Help: "Transaction fees paid, in ETH.",
+		}),
+		walletBalance: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "oracle_wallet_balance_eth",
+			Help: "Balance of the node account, in ETH.",
+		}),
+		balanceLevel: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "oracle_wallet_balance_level",
+			Help: "Level of the node balance: 0 ok, 1 below warn_balance_eth, 2 below min_balance_eth (submissions paused).",
+		}),
+		lastSubmittedPrice: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "oracle_last_submitted_price",
+			Help: "Last price submitted on-chain, per coin.",
+		}, []string{"coin"}),
+		// A timestamp rather than an age, which would freeze when reads fail: alert on time() - it
+		onChainUpdatedAt: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "oracle_onchain_price_updated_timestamp_seconds",
+			Help: "Unix time of the block that finalized the on-chain price, per coin.",
+		}, []string{"coin"}),
+		roundsMissed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "oracle_rounds_missed_total",
+			Help: "Rounds finalized without a submission from this node, per coin.",
+		}, []string{"coin"}),
+	}
+
+	reg.MustRegister(
+		m.submissionsAttempted, m.submissionsSucceeded, m.submissionsReverted, m.submissionsSkipped, m.submissionErrors,
+		m.fetchDuration, m.fetchErrors,
+		m.gasUsed, m.feesSpent, m.walletBalance, m.balanceLevel,
+		m.lastSubmittedPrice, m.onChainUpdatedAt, m.roundsMissed,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "oracle_nonce",
+			Help: "Next nonce the node will use.",
+		}, func() float64 { return float64(txManager.Nonce()) }),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveFetch records the latency and outcome of one price request
+func (m *Metrics) ObserveFetch(source string, duration time.Duration, err error) {
+	m.fetchDuration.WithLabelValues(source).Observe(duration.Seconds())
+	if err != nil {
+		m.fetchErrors.WithLabelValues(source).Inc()
+	}
+}
+
+// ObserveReceipt records gas and fees of a mined transaction
+func (m *Metrics) ObserveReceipt(receipt *types.Receipt) {
+	m.gasUsed.Add(float64(receipt.GasUsed))
+	if receipt.EffectiveGasPrice != nil {
+		fee := new(big.Int).Mul(receipt.EffectiveGasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		m.feesSpent.Add(weiToEth(fee))
+	}
+}
+
+// SetBalance records the balance of the node account
+func (m *Metrics) SetBalance(balance *big.Int) {
+	m.walletBalance.Set(weiToEth(balance))
+}
+
+// SetOnChainUpdatedAt records the time the on-chain price of a coin was finalized
+func (m *Metrics) SetOnChainUpdatedAt(coin string, updatedAt time.Time) {
+	m.onChainUpdatedAt.WithLabelValues(coin).Set(float64(updatedAt.Unix()))
+}
+
+// Convert wei to ETH, precise enough for monitoring
+func weiToEth(wei *big.Int) float64 {
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return eth
+}
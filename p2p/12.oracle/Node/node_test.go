@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Feed of the legacy ethereum coin, what the frontend reads
+var ethereumFeed = Feed{Key: "ethereum", Coin: "ethereum", Base: "ETH", Quote: "USD", Decimals: defaultDecimals}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestRegistration(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1)
+	node := chain.newNode(t, chain.nodeConfig(0, newMockCoinGecko(t)))
+	opts := &bind.CallOpts{Context: ctx}
+
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatalf("EnsureRegistered: %v", err)
+	}
+	if isNode, err := chain.contract.IsNode(opts, node.address); err != nil || !isNode {
+		t.Fatalf("isNode = %t, %v after registration", isNode, err)
+	}
+	nodes, err := registeredNodes(ctx, chain.contract)
+	if err != nil || len(nodes) != 1 || nodes[0] != node.address {
+		t.Fatalf("registered nodes = %v, %v, want [%s]", nodes, err, node.address.Hex())
+	}
+
+	// Already registered: no transaction
+	nonce := node.txManager.Nonce()
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatalf("second EnsureRegistered: %v", err)
+	}
+	if node.txManager.Nonce() != nonce {
+		t.Errorf("second EnsureRegistered sent a transaction")
+	}
+
+	if err := node.Deregister(ctx); err != nil {
+		t.Fatalf("Deregister: %v", err)
+	}
+	if isNode, err := chain.contract.IsNode(opts, node.address); err != nil || isNode {
+		t.Fatalf("isNode = %t, %v after deregistration", isNode, err)
+	}
+	if nodes, err := registeredNodes(ctx, chain.contract); err != nil || len(nodes) != 0 {
+		t.Fatalf("registered nodes = %v, %v after deregistration", nodes, err)
+	}
+}
+
+func TestSubmission(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1)
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000.123456785")
+	node := chain.newNode(t, chain.nodeConfig(0, coingecko))
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("SubmitPrice: %v", err)
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	price, err := chain.contract.NodePrices(opts, "ethereum", big.NewInt(0), node.address)
+	if err != nil || price.String() != "300012345679" {
+		t.Errorf("nodePrices = %v, %v, want 300012345679 (rounded half away from zero)", price, err)
+	}
+	round, err := chain.contract.Rounds(opts, "ethereum")
+	if err != nil || round.Id.Sign() != 0 || round.TotalSubmissionCount.Int64() != 1 {
+		t.Errorf("round = %+v, %v, want round 0 with 1 submission", round, err)
+	}
+
+	node.mu.Lock()
+	submission := node.coinStatus["ethereum"].Submission
+	node.mu.Unlock()
+	if submission == nil || submission.Status != "success" || submission.RoundID != "0" || !strings.HasPrefix(submission.TxHash, "0x") {
+		t.Errorf("last submission = %+v, want a successful submission for round 0", submission)
+	}
+
+	// One submission per round: the next tick waits for the quorum without a transaction
+	nonce := node.txManager.Nonce()
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("second SubmitPrice: %v", err)
+	}
+	if node.txManager.Nonce() != nonce {
+		t.Errorf("second SubmitPrice sent a transaction in the same round")
+	}
+}
+
+func TestSubmissionReverts(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1)
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	node := chain.newNode(t, chain.nodeConfig(0, coingecko))
+
+	// Not registered: the contract refuses the price
+	err := node.SubmitPrice(ctx, ethereumFeed)
+	var revert *RevertError
+	if !errors.Is(err, ErrReverted) || !errors.As(err, &revert) || revert.Reason != "Not a node" {
+		t.Fatalf("SubmitPrice from an unregistered node = %v, want a Not a node revert", err)
+	}
+	node.mu.Lock()
+	submission := node.coinStatus["ethereum"].Submission
+	node.mu.Unlock()
+	if submission == nil || submission.Status != "failed" || !strings.Contains(submission.Error, "Not a node") {
+		t.Errorf("last submission = %+v, want a failed submission with the revert reason", submission)
+	}
+
+	// Registered, but a second price in the same round
+	if err := node.EnsureRegistered(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("SubmitPrice: %v", err)
+	}
+	data, err := node.contractABI.Pack("submitPrice", "ethereum", big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.txManager.Send(ctx, "submitPrice ethereum", node.contractAddress, data); !errors.As(err, &revert) ||
+		revert.Reason != "Already submitted for this round" {
+		t.Fatalf("double submission = %v, want an Already submitted for this round revert", err)
+	}
+
+	// A failed send must not burn the nonce of the next transaction
+	if err := node.Deregister(ctx); err != nil {
+		t.Fatalf("Deregister after a revert: %v", err)
+	}
+}
+
+func TestQuorumFinalization(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 4) // Quorum of 4 nodes is 3
+	prices := []string{"3000", "3000.5", "3001.25", "2000"}
+
+	var nodes []*OracleNode
+	for i, price := range prices {
+		coingecko := newMockCoinGecko(t)
+		coingecko.SetPrice("ethereum", "usd", price)
+		node := chain.newNode(t, chain.nodeConfig(i, coingecko))
+		if err := node.EnsureRegistered(ctx); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	opts := &bind.CallOpts{Context: ctx}
+	if quorum, err := chain.contract.GetQuorum(opts); err != nil || quorum.Int64() != 3 {
+		t.Fatalf("quorum = %v, %v, want 3", quorum, err)
+	}
+
+	watchCtx, stopWatcher := context.WithCancel(ctx)
+	defer stopWatcher()
+	go nodes[0].watcher.Run(watchCtx)
+
+	// Events are filtered from the block before the first submission
+	startBlock, err := chain.client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range nodes[:3] {
+		if err := node.SubmitPrice(ctx, ethereumFeed); err != nil {
+			t.Fatalf("%s: SubmitPrice: %v", node.config.Name, err)
+		}
+	}
+
+	// (3000 + 3000.5 + 3001.25) / 3 with 8 decimals
+	want := "300058333333"
+	if price, err := chain.contract.CurrentPrices(opts, "ethereum"); err != nil || price.String() != want {
+		t.Fatalf("currentPrices = %v, %v, want %s", price, err, want)
+	}
+	round, err := chain.contract.Rounds(opts, "ethereum")
+	if err != nil || round.Id.Int64() != 1 || round.TotalSubmissionCount.Sign() != 0 || round.LastUpdatedAt.Sign() == 0 {
+		t.Fatalf("round = %+v, %v, want round 1 opened with no submission", round, err)
+	}
+
+	filterCtx, cancelFilter := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFilter()
+	events, err := chain.contract.FilterPriceUpdated(&bind.FilterOpts{Start: startBlock, Context: filterCtx}, []string{"ethereum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var finalized []string
+	for events.Next() {
+		finalized = append(finalized, events.Event.RoundId.String()+":"+events.Event.Price.String())
+	}
+	if err := events.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(finalized) != 1 || finalized[0] != "0:"+want {
+		t.Errorf("PriceUpdated events = %v, want [0:%s]", finalized, want)
+	}
+
+	// The watcher of the first node saw the round it took part in
+	eventually(t, 10*time.Second, func() bool {
+		view, ok := nodes[0].watcher.Round("ethereum")
+		return ok && view.FinalizedRound != nil && view.FinalizedRound.Sign() == 0
+	}, "watcher never saw round 0 finalized")
+	eventually(t, 5*time.Second, func() bool {
+		var gauge dto.Metric
+		nodes[0].metrics.onChainUpdatedAt.WithLabelValues("ethereum").Write(&gauge)
+		return gauge.GetGauge().GetValue() == float64(round.LastUpdatedAt.Int64())
+	}, "on-chain update time not exported")
+
+	// The late node starts the next round
+	if err := nodes[3].SubmitPrice(ctx, ethereumFeed); err != nil {
+		t.Fatalf("late SubmitPrice: %v", err)
+	}
+	submitted, err := chain.contract.HasSubmitted(opts, "ethereum", big.NewInt(1), nodes[3].address)
+	if err != nil || !submitted {
+		t.Errorf("late node submitted in round 1 = %t, %v", submitted, err)
+	}
+}
+
+func TestLivenessDuringSlowCycle(t *testing.T) {
+	chain := newTestChain(t, 1)
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	coingecko.SetLatency(3 * time.Second) // Longer than the stale limit of 1s
+	cfg := chain.nodeConfig(0, coingecko)
+	cfg.SubmissionInterval = 1
+	cfg.StaleIntervals = 1
+	node := chain.newNode(t, cfg)
+
+	ctx, stop := context.WithCancel(testContext(t))
+	stopped := make(chan struct{})
+	go func() {
+		node.StartPriceSubmissionLoop(ctx)
+		close(stopped)
+	}()
+
+	// The loop keeps ticking while the first cycle waits on its sources
+	time.Sleep(2500 * time.Millisecond)
+	if check := node.livenessChecks(ctx)["loop"]; check.Status != CheckOK {
+		t.Errorf("liveness during a slow cycle = %+v, want ok", check)
+	}
+	stop()
+	<-stopped
+}
@@ -26,6 +26,7 @@ var (
 	_ = common.Big1
 	_ = types.BloomLookup
 	_ = event.NewSubscription
+	_ = abi.ConvertType
 )
 
 // OracleMetaData contains all meta data concerning the Oracle contract.
@@ -59,6 +60,43 @@ type OracleFilterer struct {
 	contract *bind.BoundContract // Generic contract wrapper for the low level calls
 }
 
+// OracleSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type OracleSession struct {
+	Contract     *Oracle           // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// OracleCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type OracleCallerSession struct {
+	Contract *OracleCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts // Call options to use throughout this session
+}
+
+// OracleTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type OracleTransactorSession struct {
+	Contract     *OracleTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// OracleRaw is an auto generated low-level Go binding around an Ethereum contract.
+type OracleRaw struct {
+	Contract *Oracle // Generic contract binding to access the raw methods on
+}
+
+// OracleCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type OracleCallerRaw struct {
+	Contract *OracleCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// OracleTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type OracleTransactorRaw struct {
+	Contract *OracleTransactor // Generic write-only contract binding to access the raw methods on
+}
+
 // NewOracle creates a new instance of Oracle, bound to a specific deployed contract.
 func NewOracle(address common.Address, backend bind.ContractBackend) (*Oracle, error) {
 	contract, err := bindOracle(address, backend, backend, backend)
@@ -68,43 +106,552 @@ func NewOracle(address common.Address, backend bind.ContractBackend) (*Oracle, e
 	return &Oracle{OracleCaller: OracleCaller{contract: contract}, OracleTransactor: OracleTransactor{contract: contract}, OracleFilterer: OracleFilterer{contract: contract}}, nil
 }
 
+// NewOracleCaller creates a new read-only instance of Oracle, bound to a specific deployed contract.
+func NewOracleCaller(address common.Address, caller bind.ContractCaller) (*OracleCaller, error) {
+	contract, err := bindOracle(address, caller, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &OracleCaller{contract: contract}, nil
+}
+
+// NewOracleTransactor creates a new write-only instance of Oracle, bound to a specific deployed contract.
+func NewOracleTransactor(address common.Address, transactor bind.ContractTransactor) (*OracleTransactor, error) {
+	contract, err := bindOracle(address, nil, transactor, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &OracleTransactor{contract: contract}, nil
+}
+
+// NewOracleFilterer creates a new log filterer instance of Oracle, bound to a specific deployed contract.
+func NewOracleFilterer(address common.Address, filterer bind.ContractFilterer) (*OracleFilterer, error) {
+	contract, err := bindOracle(address, nil, nil, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &OracleFilterer{contract: contract}, nil
+}
+
 // bindOracle binds a generic wrapper to an already deployed contract.
 func bindOracle(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor, filterer bind.ContractFilterer) (*bind.BoundContract, error) {
-	parsed, err := abi.JSON(strings.NewReader(OracleABI))
+	parsed, err := OracleMetaData.GetAbi()
 	if err != nil {
 		return nil, err
 	}
-	return bind.NewBoundContract(address, parsed, caller, transactor, filterer), nil
+	return bind.NewBoundContract(address, *parsed, caller, transactor, filterer), nil
 }
 
-// AddNode is a paid mutator transaction binding the contract method.
-func (_Oracle *OracleTransactor) AddNode(opts *bind.TransactOpts) (*types.Transaction, error) {
-	return _Oracle.contract.Transact(opts, "addNode")
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Oracle *OracleRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Oracle.Contract.OracleCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Oracle *OracleRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Oracle.Contract.OracleTransactor.contract.Transfer(opts)
 }
 
-// IsNode is a free data retrieval call binding the contract method.
-func (_Oracle *OracleCaller) IsNode(opts *bind.CallOpts, node common.Address) (bool, error) {
+// Transact invokes the (paid) contract method with params as input values.
+func (_Oracle *OracleRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Oracle.Contract.OracleTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_Oracle *OracleCallerRaw) Call(opts *bind.CallOpts, result *[]interface{}, method string, params ...interface{}) error {
+	return _Oracle.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_Oracle *OracleTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Oracle.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_Oracle *OracleTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _Oracle.Contract.contract.Transact(opts, method, params...)
+}
+
+// CurrentPrices is a free data retrieval call binding the contract method 0xc36a2ad6.
+//
+// Solidity: function currentPrices(string ) view returns(uint256)
+func (_Oracle *OracleCaller) CurrentPrices(opts *bind.CallOpts, arg0 string) (*big.Int, error) {
 	var out []interface{}
-	err := _Oracle.contract.Call(opts, &out, "isNode", node)
+	err := _Oracle.contract.Call(opts, &out, "currentPrices", arg0)
+
 	if err != nil {
-		return false, err
+		return *new(*big.Int), err
 	}
-	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
 	return out0, err
+
 }
 
-// SubmitPrice is a paid mutator transaction binding the contract method 0xfd52762c.
-func (_Oracle *OracleTransactor) SubmitPrice(opts *bind.TransactOpts, coin string, price *big.Int) (*types.Transaction, error) {
-	return _Oracle.contract.Transact(opts, "submitPrice", coin, price)
+// CurrentPrices is a free data retrieval call binding the contract method 0xc36a2ad6.
+//
+// Solidity: function currentPrices(string ) view returns(uint256)
+func (_Oracle *OracleSession) CurrentPrices(arg0 string) (*big.Int, error) {
+	return _Oracle.Contract.CurrentPrices(&_Oracle.CallOpts, arg0)
 }
 
 // CurrentPrices is a free data retrieval call binding the contract method 0xc36a2ad6.
-func (_Oracle *OracleCaller) CurrentPrices(opts *bind.CallOpts, coin string) (*big.Int, error) {
+//
+// Solidity: function currentPrices(string ) view returns(uint256)
+func (_Oracle *OracleCallerSession) CurrentPrices(arg0 string) (*big.Int, error) {
+	return _Oracle.Contract.CurrentPrices(&_Oracle.CallOpts, arg0)
+}
+
+// GetQuorum is a free data retrieval call binding the contract method 0xc26c12eb.
+//
+// Solidity: function getQuorum() view returns(uint256)
+func (_Oracle *OracleCaller) GetQuorum(opts *bind.CallOpts) (*big.Int, error) {
 	var out []interface{}
-	err := _Oracle.contract.Call(opts, &out, "currentPrices", coin)
+	err := _Oracle.contract.Call(opts, &out, "getQuorum")
+
 	if err != nil {
 		return *new(*big.Int), err
 	}
+
 	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
 	return out0, err
+
+}
+
+// GetQuorum is a free data retrieval call binding the contract method 0xc26c12eb.
+//
+// Solidity: function getQuorum() view returns(uint256)
+func (_Oracle *OracleSession) GetQuorum() (*big.Int, error) {
+	return _Oracle.Contract.GetQuorum(&_Oracle.CallOpts)
+}
+
+// GetQuorum is a free data retrieval call binding the contract method 0xc26c12eb.
+//
+// Solidity: function getQuorum() view returns(uint256)
+func (_Oracle *OracleCallerSession) GetQuorum() (*big.Int, error) {
+	return _Oracle.Contract.GetQuorum(&_Oracle.CallOpts)
+}
+
+// HasSubmitted is a free data retrieval call binding the contract method 0xeeb530d1.
+//
+// Solidity: function hasSubmitted(string , uint256 , address ) view returns(bool)
+func (_Oracle *OracleCaller) HasSubmitted(opts *bind.CallOpts, arg0 string, arg1 *big.Int, arg2 common.Address) (bool, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "hasSubmitted", arg0, arg1, arg2)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// HasSubmitted is a free data retrieval call binding the contract method 0xeeb530d1.
+//
+// Solidity: function hasSubmitted(string , uint256 , address ) view returns(bool)
+func (_Oracle *OracleSession) HasSubmitted(arg0 string, arg1 *big.Int, arg2 common.Address) (bool, error) {
+	return _Oracle.Contract.HasSubmitted(&_Oracle.CallOpts, arg0, arg1, arg2)
+}
+
+// HasSubmitted is a free data retrieval call binding the contract method 0xeeb530d1.
+//
+// Solidity: function hasSubmitted(string , uint256 , address ) view returns(bool)
+func (_Oracle *OracleCallerSession) HasSubmitted(arg0 string, arg1 *big.Int, arg2 common.Address) (bool, error) {
+	return _Oracle.Contract.HasSubmitted(&_Oracle.CallOpts, arg0, arg1, arg2)
+}
+
+// IsNode is a free data retrieval call binding the contract method 0x01750152.
+//
+// Solidity: function isNode(address ) view returns(bool)
+func (_Oracle *OracleCaller) IsNode(opts *bind.CallOpts, arg0 common.Address) (bool, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "isNode", arg0)
+
+	if err != nil {
+		return *new(bool), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(bool)).(*bool)
+
+	return out0, err
+
+}
+
+// IsNode is a free data retrieval call binding the contract method 0x01750152.
+//
+// Solidity: function isNode(address ) view returns(bool)
+func (_Oracle *OracleSession) IsNode(arg0 common.Address) (bool, error) {
+	return _Oracle.Contract.IsNode(&_Oracle.CallOpts, arg0)
+}
+
+// IsNode is a free data retrieval call binding the contract method 0x01750152.
+//
+// Solidity: function isNode(address ) view returns(bool)
+func (_Oracle *OracleCallerSession) IsNode(arg0 common.Address) (bool, error) {
+	return _Oracle.Contract.IsNode(&_Oracle.CallOpts, arg0)
+}
+
+// NodePrices is a free data retrieval call binding the contract method 0xd8d0f038.
+//
+// Solidity: function nodePrices(string , uint256 , address ) view returns(uint256)
+func (_Oracle *OracleCaller) NodePrices(opts *bind.CallOpts, arg0 string, arg1 *big.Int, arg2 common.Address) (*big.Int, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "nodePrices", arg0, arg1, arg2)
+
+	if err != nil {
+		return *new(*big.Int), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+
+	return out0, err
+
+}
+
+// NodePrices is a free data retrieval call binding the contract method 0xd8d0f038.
+//
+// Solidity: function nodePrices(string , uint256 , address ) view returns(uint256)
+func (_Oracle *OracleSession) NodePrices(arg0 string, arg1 *big.Int, arg2 common.Address) (*big.Int, error) {
+	return _Oracle.Contract.NodePrices(&_Oracle.CallOpts, arg0, arg1, arg2)
+}
+
+// NodePrices is a free data retrieval call binding the contract method 0xd8d0f038.
+//
+// Solidity: function nodePrices(string , uint256 , address ) view returns(uint256)
+func (_Oracle *OracleCallerSession) NodePrices(arg0 string, arg1 *big.Int, arg2 common.Address) (*big.Int, error) {
+	return _Oracle.Contract.NodePrices(&_Oracle.CallOpts, arg0, arg1, arg2)
+}
+
+// Nodes is a free data retrieval call binding the contract method 0x1c53c280.
+//
+// Solidity: function nodes(uint256 ) view returns(address)
+func (_Oracle *OracleCaller) Nodes(opts *bind.CallOpts, arg0 *big.Int) (common.Address, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "nodes", arg0)
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// Nodes is a free data retrieval call binding the contract method 0x1c53c280.
+//
+// Solidity: function nodes(uint256 ) view returns(address)
+func (_Oracle *OracleSession) Nodes(arg0 *big.Int) (common.Address, error) {
+	return _Oracle.Contract.Nodes(&_Oracle.CallOpts, arg0)
+}
+
+// Nodes is a free data retrieval call binding the contract method 0x1c53c280.
+//
+// Solidity: function nodes(uint256 ) view returns(address)
+func (_Oracle *OracleCallerSession) Nodes(arg0 *big.Int) (common.Address, error) {
+	return _Oracle.Contract.Nodes(&_Oracle.CallOpts, arg0)
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_Oracle *OracleCaller) Owner(opts *bind.CallOpts) (common.Address, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "owner")
+
+	if err != nil {
+		return *new(common.Address), err
+	}
+
+	out0 := *abi.ConvertType(out[0], new(common.Address)).(*common.Address)
+
+	return out0, err
+
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_Oracle *OracleSession) Owner() (common.Address, error) {
+	return _Oracle.Contract.Owner(&_Oracle.CallOpts)
+}
+
+// Owner is a free data retrieval call binding the contract method 0x8da5cb5b.
+//
+// Solidity: function owner() view returns(address)
+func (_Oracle *OracleCallerSession) Owner() (common.Address, error) {
+	return _Oracle.Contract.Owner(&_Oracle.CallOpts)
+}
+
+// Rounds is a free data retrieval call binding the contract method 0x96af8753.
+//
+// Solidity: function rounds(string ) view returns(uint256 id, uint256 totalSubmissionCount, uint256 lastUpdatedAt)
+func (_Oracle *OracleCaller) Rounds(opts *bind.CallOpts, arg0 string) (struct {
+	Id                   *big.Int
+	TotalSubmissionCount *big.Int
+	LastUpdatedAt        *big.Int
+}, error) {
+	var out []interface{}
+	err := _Oracle.contract.Call(opts, &out, "rounds", arg0)
+
+	outstruct := new(struct {
+		Id                   *big.Int
+		TotalSubmissionCount *big.Int
+		LastUpdatedAt        *big.Int
+	})
+	if err != nil {
+		return *outstruct, err
+	}
+
+	outstruct.Id = *abi.ConvertType(out[0], new(*big.Int)).(**big.Int)
+	outstruct.TotalSubmissionCount = *abi.ConvertType(out[1], new(*big.Int)).(**big.Int)
+	outstruct.LastUpdatedAt = *abi.ConvertType(out[2], new(*big.Int)).(**big.Int)
+
+	return *outstruct, err
+
+}
+
+// Rounds is a free data retrieval call binding the contract method 0x96af8753.
+//
+// Solidity: function rounds(string ) view returns(uint256 id, uint256 totalSubmissionCount, uint256 lastUpdatedAt)
+func (_Oracle *OracleSession) Rounds(arg0 string) (struct {
+	Id                   *big.Int
+	TotalSubmissionCount *big.Int
+	LastUpdatedAt        *big.Int
+}, error) {
+	return _Oracle.Contract.Rounds(&_Oracle.CallOpts, arg0)
+}
+
+// Rounds is a free data retrieval call binding the contract method 0x96af8753.
+//
+// Solidity: function rounds(string ) view returns(uint256 id, uint256 totalSubmissionCount, uint256 lastUpdatedAt)
+func (_Oracle *OracleCallerSession) Rounds(arg0 string) (struct {
+	Id                   *big.Int
+	TotalSubmissionCount *big.Int
+	LastUpdatedAt        *big.Int
+}, error) {
+	return _Oracle.Contract.Rounds(&_Oracle.CallOpts, arg0)
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0xe07c60e1.
+//
+// Solidity: function addNode() returns()
+func (_Oracle *OracleTransactor) AddNode(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Oracle.contract.Transact(opts, "addNode")
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0xe07c60e1.
+//
+// Solidity: function addNode() returns()
+func (_Oracle *OracleSession) AddNode() (*types.Transaction, error) {
+	return _Oracle.Contract.AddNode(&_Oracle.TransactOpts)
+}
+
+// AddNode is a paid mutator transaction binding the contract method 0xe07c60e1.
+//
+// Solidity: function addNode() returns()
+func (_Oracle *OracleTransactorSession) AddNode() (*types.Transaction, error) {
+	return _Oracle.Contract.AddNode(&_Oracle.TransactOpts)
+}
+
+// RemoveNode is a paid mutator transaction binding the contract method 0xd0d3f5ba.
+//
+// Solidity: function removeNode() returns()
+func (_Oracle *OracleTransactor) RemoveNode(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _Oracle.contract.Transact(opts, "removeNode")
+}
+
+// RemoveNode is a paid mutator transaction binding the contract method 0xd0d3f5ba.
+//
+// Solidity: function removeNode() returns()
+func (_Oracle *OracleSession) RemoveNode() (*types.Transaction, error) {
+	return _Oracle.Contract.RemoveNode(&_Oracle.TransactOpts)
+}
+
+// RemoveNode is a paid mutator transaction binding the contract method 0xd0d3f5ba.
+//
+// Solidity: function removeNode() returns()
+func (_Oracle *OracleTransactorSession) RemoveNode() (*types.Transaction, error) {
+	return _Oracle.Contract.RemoveNode(&_Oracle.TransactOpts)
+}
+
+// SubmitPrice is a paid mutator transaction binding the contract method 0xfd52762c.
+//
+// Solidity: function submitPrice(string coin, uint256 price) returns()
+func (_Oracle *OracleTransactor) SubmitPrice(opts *bind.TransactOpts, coin string, price *big.Int) (*types.Transaction, error) {
+	return _Oracle.contract.Transact(opts, "submitPrice", coin, price)
+}
+
+// SubmitPrice is a paid mutator transaction binding the contract method 0xfd52762c.
+//
+// Solidity: function submitPrice(string coin, uint256 price) returns()
+func (_Oracle *OracleSession) SubmitPrice(coin string, price *big.Int) (*types.Transaction, error) {
+	return _Oracle.Contract.SubmitPrice(&_Oracle.TransactOpts, coin, price)
+}
+
+// SubmitPrice is a paid mutator transaction binding the contract method 0xfd52762c.
+//
+// Solidity: function submitPrice(string coin, uint256 price) returns()
+func (_Oracle *OracleTransactorSession) SubmitPrice(coin string, price *big.Int) (*types.Transaction, error) {
+	return _Oracle.Contract.SubmitPrice(&_Oracle.TransactOpts, coin, price)
+}
+
+// OraclePriceUpdatedIterator is returned from FilterPriceUpdated and is used to iterate over the raw logs and unpacked data for PriceUpdated events raised by the Oracle contract.
+type OraclePriceUpdatedIterator struct {
+	Event *OraclePriceUpdated // Event containing the contract specifics and raw log
+
+	contract *bind.BoundContract // Generic contract to use for unpacking event data
+	event    string              // Event name to use for unpacking event data
+
+	logs chan types.Log        // Log channel receiving the found contract events
+	sub  ethereum.Subscription // Subscription for errors, completion and termination
+	done bool                  // Whether the subscription completed delivering logs
+	fail error                 // Occurred error to stop iteration
+}
+
+// Next advances the iterator to the subsequent event, returning whether there
+// are any more events found. In case of a retrieval or parsing error, false is
+// returned and Error() can be queried for the exact failure.
+func (it *OraclePriceUpdatedIterator) Next() bool {
+	// If the iterator failed, stop iterating
+	if it.fail != nil {
+		return false
+	}
+	// If the iterator completed, deliver directly whatever's available
+	if it.done {
+		select {
+		case log := <-it.logs:
+			it.Event = new(OraclePriceUpdated)
+			if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+				it.fail = err
+				return false
+			}
+			it.Event.Raw = log
+			return true
+
+		default:
+			return false
+		}
+	}
+	// Iterator still in progress, wait for either a data or an error event
+	select {
+	case log := <-it.logs:
+		it.Event = new(OraclePriceUpdated)
+		if err := it.contract.UnpackLog(it.Event, it.event, log); err != nil {
+			it.fail = err
+			return false
+		}
+		it.Event.Raw = log
+		return true
+
+	case err := <-it.sub.Err():
+		it.done = true
+		it.fail = err
+		return it.Next()
+	}
+}
+
+// Error returns any retrieval or parsing error occurred during filtering.
+func (it *OraclePriceUpdatedIterator) Error() error {
+	return it.fail
+}
+
+// Close terminates the iteration process, releasing any pending underlying
+// resources.
+func (it *OraclePriceUpdatedIterator) Close() error {
+	it.sub.Unsubscribe()
+	return nil
+}
+
+// OraclePriceUpdated represents a PriceUpdated event raised by the Oracle contract.
+type OraclePriceUpdated struct {
+	Coin    common.Hash
+	Price   *big.Int
+	RoundId *big.Int
+	Raw     types.Log // Blockchain specific contextual infos
+}
+
+// FilterPriceUpdated is a free log retrieval operation binding the contract event 0x6e838f2a03741f5f2aff5480963b672fb0dd8430a4dc75db9b67ce009733c9fe.
+//
+// Solidity: event PriceUpdated(string indexed coin, uint256 price, uint256 roundId)
+func (_Oracle *OracleFilterer) FilterPriceUpdated(opts *bind.FilterOpts, coin []string) (*OraclePriceUpdatedIterator, error) {
+
+	var coinRule []interface{}
+	for _, coinItem := range coin {
+		coinRule = append(coinRule, coinItem)
+	}
+
+	logs, sub, err := _Oracle.contract.FilterLogs(opts, "PriceUpdated", coinRule)
+	if err != nil {
+		return nil, err
+	}
+	return &OraclePriceUpdatedIterator{contract: _Oracle.contract, event: "PriceUpdated", logs: logs, sub: sub}, nil
+}
+
+// WatchPriceUpdated is a free log subscription operation binding the contract event 0x6e838f2a03741f5f2aff5480963b672fb0dd8430a4dc75db9b67ce009733c9fe.
+//
+// Solidity: event PriceUpdated(string indexed coin, uint256 price, uint256 roundId)
+func (_Oracle *OracleFilterer) WatchPriceUpdated(opts *bind.WatchOpts, sink chan<- *OraclePriceUpdated, coin []string) (event.Subscription, error) {
+
+	var coinRule []interface{}
+	for _, coinItem := range coin {
+		coinRule = append(coinRule, coinItem)
+	}
+
+	logs, sub, err := _Oracle.contract.WatchLogs(opts, "PriceUpdated", coinRule)
+	if err != nil {
+		return nil, err
+	}
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case log := <-logs:
+				// New log arrived, parse the event and forward to the user
+				event := new(OraclePriceUpdated)
+				if err := _Oracle.contract.UnpackLog(event, "PriceUpdated", log); err != nil {
+					return err
+				}
+				event.Raw = log
+
+				select {
+				case sink <- event:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// ParsePriceUpdated is a log parse operation binding the contract event 0x6e838f2a03741f5f2aff5480963b672fb0dd8430a4dc75db9b67ce009733c9fe.
+//
+// Solidity: event PriceUpdated(string indexed coin, uint256 price, uint256 roundId)
+func (_Oracle *OracleFilterer) ParsePriceUpdated(log types.Log) (*OraclePriceUpdated, error) {
+	event := new(OraclePriceUpdated)
+	if err := _Oracle.contract.UnpackLog(event, "PriceUpdated", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// SubmissionPolicy decides when a fresh off-chain price is worth a transaction,
+// Chainlink style: on a large enough deviation or when the on-chain value gets too old
+type SubmissionPolicy struct {
+	// Minimum deviation from the on-chain price, in basis points (1 bps = 0.01%)
+	DeviationBps int64
+
+	// Maximum age of the on-chain price before a submission is forced
+	Heartbeat time.Duration
+}
+
+// OnChainPrice is the finalized price of a coin and the state of its current round
+type OnChainPrice struct {
+	Price         *big.Int
+	RoundID       *big.Int
+	Submissions   *big.Int
+	LastUpdatedAt time.Time
+	Block         uint64 // Block the state was read at
+
+	// Whether this node already submitted for the current round
+	Submitted bool
+}
+
+// Read the finalized price and current round of a coin from the contract
+// All values are read at the same block so the round cannot change in between
+func (n *OracleNode) onChainPrice(ctx context.Context, coin string) (*OnChainPrice, error) {
+	head, err := n.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", err)
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(head)}
+
+	price, err := n.contract.OracleCaller.CurrentPrices(opts, coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current price: %w", err)
+	}
+
+	round, err := n.contract.OracleCaller.Rounds(opts, coin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read round: %w", err)
+	}
+
+	submitted, err := n.contract.OracleCaller.HasSubmitted(opts, coin, round.Id, n.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submission status: %w", err)
+	}
+
+	state := &OnChainPrice{
+		Price:       price,
+		RoundID:     round.Id,
+		Submissions: round.TotalSubmissionCount,
+		Submitted:   submitted,
+		Block:       head,
+	}
+	if round.LastUpdatedAt.Sign() > 0 {
+		state.LastUpdatedAt = time.Unix(round.LastUpdatedAt.Int64(), 0)
+	}
+	return state, nil
+}
+
+// Deviation between two prices in basis points of the reference price
+func deviationBps(price, reference *big.Int) int64 {
+	if reference.Sign() == 0 {
+		return 0
+	}
+	diff := new(big.Int).Sub(price, reference)
+	diff.Abs(diff)
+	diff.Mul(diff, big.NewInt(10000))
+	diff.Quo(diff, reference)
+	if !diff.IsInt64() {
+		return int64(^uint64(0) >> 1)
+	}
+	return diff.Int64()
+}
+
+// ShouldSubmit tells whether a price must be submitted given the on-chain state, and why
+func (p SubmissionPolicy) ShouldSubmit(price *big.Int, state *OnChainPrice, now time.Time) (bool, string) {
+	if state.Price.Sign() == 0 {
+		return true, "no price on-chain yet"
+	}
+
+	// Another node opened the round: join it so it reaches quorum
+	if state.Submissions.Sign() > 0 {
+		return true, fmt.Sprintf("round %s in progress (%s submissions)", state.RoundID, state.Submissions)
+	}
+
+	deviation := deviationBps(price, state.Price)
+	if deviation >= p.DeviationBps {
+		return true, fmt.Sprintf("deviation %d bps >= %d bps", deviation, p.DeviationBps)
+	}
+
+	if p.Heartbeat > 0 && !state.LastUpdatedAt.IsZero() {
+		age := now.Sub(state.LastUpdatedAt)
+		if age >= p.Heartbeat {
+			return true, fmt.Sprintf("heartbeat: on-chain price is %s old", age.Truncate(time.Second))
+		}
+	}
+
+	return false, fmt.Sprintf("deviation %d bps < %d bps", deviation, p.DeviationBps)
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriceSource is an upstream provider able to quote a feed
+type PriceSource interface {
+	// Name identifies the source in logs
+	Name() string
+
+	// FetchPrice returns the price of the base asset of a feed in its quote currency,
+	// parsed exactly from the decimal text of the API
+	FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error)
+}
+
+// BatchPriceSource is a source able to quote several feeds in a single request
+type BatchPriceSource interface {
+	PriceSource
+
+	// FetchPrices returns the price of each feed by key, feeds missing upstream are left out
+	FetchPrices(ctx context.Context, feeds []Feed) (map[string]*big.Rat, error)
+}
+
+// Exchange tickers for the CoinGecko coin IDs we usually track
+var coinSymbols = map[string]string{
+	"ethereum":    "ETH",
+	"bitcoin":     "BTC",
+	"solana":      "SOL",
+	"binancecoin": "BNB",
+	"ripple":      "XRP",
+	"cardano":     "ADA",
+	"dogecoin":    "DOGE",
+	"polkadot":    "DOT",
+	"chainlink":   "LINK",
+	"litecoin":    "LTC",
+}
+
+// Resolve the exchange ticker of a coin, an explicit symbol always wins
+func coinSymbol(coin, symbol string) (string, error) {
+	if symbol != "" {
+		return symbol, nil
+	}
+	if s, ok := coinSymbols[coin]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("no ticker known for coin %q, set a symbol in the source config", coin)
+}
+
+// Ticker of the base asset of a feed, needed by the exchanges
+func feedBase(feed Feed) (string, error) {
+	return coinSymbol(feed.Coin, feed.Base)
+}
+
+var sourceHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// GET a JSON document and decode it into out
+func getJSON(ctx context.Context, url string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := sourceHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
+
+// Parse a price that an API returned either as a JSON number or as a string
+// getJSON keeps the text of numbers, so both are parsed exactly
+func parsePrice(v interface{}) (*big.Rat, error) {
+	switch p := v.(type) {
+	case json.Number:
+		return parseDecimal(p.String())
+	case string:
+		return parseDecimal(p)
+	}
+	return nil, fmt.Errorf("unexpected price type %T", v)
+}
+
+// Base URL of the public CoinGecko API
+const coingeckoAPIURL = "https://api.coingecko.com/api/v3"
+
+// CoinGeckoSource queries the CoinGecko simple/price endpoint
+type CoinGeckoSource struct {
+	APIKey  string
+	BaseURL string // API base URL, the public API when empty
+}
+
+func (s *CoinGeckoSource) Name() string { return "coingecko" }
+
+func (s *CoinGeckoSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	prices, err := s.FetchPrices(ctx, []Feed{feed})
+	if err != nil {
+		return nil, err
+	}
+	if price, ok := prices[feed.Key]; ok {
+		return price, nil
+	}
+	return nil, fmt.Errorf("coin not found")
+}
+
+// FetchPrices quotes all the feeds with one call, simple/price takes comma-separated
+// lists of coin ids and of quote currencies
+func (s *CoinGeckoSource) FetchPrices(ctx context.Context, feeds []Feed) (map[string]*big.Rat, error) {
+	var ids, currencies []string
+	for _, feed := range feeds {
+		if !slices.Contains(ids, feed.Coin) {
+			ids = append(ids, feed.Coin)
+		}
+		if quote := strings.ToLower(feed.Quote); !slices.Contains(currencies, quote) {
+			currencies = append(currencies, quote)
+		}
+	}
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = coingeckoAPIURL
+	}
+	url := fmt.Sprintf("%s/simple/price?ids=%s&vs_currencies=%s",
+		strings.TrimSuffix(baseURL, "/"), neturl.QueryEscape(strings.Join(ids, ",")), neturl.QueryEscape(strings.Join(currencies, ",")))
+
+	headers := map[string]string{}
+	if s.APIKey != "" {
+		headers["x-cg-demo-api-key"] = s.APIKey
+	}
+
+	var result map[string]map[string]json.Number
+	if err := getJSON(ctx, url, headers, &result); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]*big.Rat, len(feeds))
+	for _, feed := range feeds {
+		if value, ok := result[feed.Coin][strings.ToLower(feed.Quote)]; ok {
+			price, err := parseDecimal(value.String())
+			if err != nil {
+				return nil, fmt.Errorf("invalid price for %s: %w", feed, err)
+			}
+			prices[feed.Key] = price
+		}
+	}
+	return prices, nil
+}
+
+// BinanceSource queries the Binance spot ticker, quoted in USDT
+type BinanceSource struct {
+	Symbol string
+}
+
+func (s *BinanceSource) Name() string { return "binance" }
+
+// Market of a feed on Binance, USD is quoted in USDT
+func (s *BinanceSource) market(feed Feed) (string, error) {
+	if s.Symbol != "" {
+		return s.Symbol, nil
+	}
+	base, err := feedBase(feed)
+	if err != nil {
+		return "", err
+	}
+	quote := feed.Quote
+	if quote == "USD" {
+		quote = "USDT"
+	}
+	return base + quote, nil
+}
+
+func (s *BinanceSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	symbol, err := s.market(feed)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("https://api.binance.com/api/v3/ticker/price?symbol=%s", symbol)
+
+	var result struct {
+		Price string `json:"price"`
+	}
+	if err := getJSON(ctx, url, nil, &result); err != nil {
+		return nil, err
+	}
+	return parseDecimal(result.Price)
+}
+
+// FetchPrices quotes all the feeds with one call of the symbols=["A","B"] form of the ticker
+func (s *BinanceSource) FetchPrices(ctx context.Context, feeds []Feed) (map[string]*big.Rat, error) {
+	markets := make(map[string][]string) // market -> feed keys
+	var quoted []string
+	for _, feed := range feeds {
+		symbol, err := s.market(feed)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := markets[symbol]; !ok {
+			quoted = append(quoted, strconv.Quote(symbol))
+		}
+		markets[symbol] = append(markets[symbol], feed.Key)
+	}
+	url := "https://api.binance.com/api/v3/ticker/price?symbols=" + neturl.QueryEscape("["+strings.Join(quoted, ",")+"]")
+
+	var result []struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	if err := getJSON(ctx, url, nil, &result); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]*big.Rat, len(feeds))
+	for _, ticker := range result {
+		price, err := parseDecimal(ticker.Price)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", ticker.Symbol, err)
+		}
+		for _, key := range markets[ticker.Symbol] {
+			prices[key] = price
+		}
+	}
+	return prices, nil
+}
+
+// Base URL of the Kraken public API
+const krakenAPIURL = "https://api.kraken.com"
+
+// KrakenSource queries the Kraken public ticker, using the last trade price
+type KrakenSource struct {
+	Symbol  string
+	BaseURL string // API base URL, the public API when empty
+}
+
+func (s *KrakenSource) Name() string { return "kraken" }
+
+// Kraken names bitcoin XBT
+func krakenTicker(ticker string) string {
+	if ticker == "BTC" {
+		return "XBT"
+	}
+	return ticker
+}
+
+// Pair of a feed on Kraken and the names the ticker may answer it under: legacy
+// pairs come back with their full asset names (ETHUSD -> XETHZUSD, ETHXBT -> XETHXXBT)
+func (s *KrakenSource) pair(feed Feed) (string, []string, error) {
+	if s.Symbol != "" {
+		return s.Symbol, []string{strings.ToUpper(s.Symbol)}, nil
+	}
+	base, err := feedBase(feed)
+	if err != nil {
+		return "", nil, err
+	}
+	base, quote := krakenTicker(base), krakenTicker(feed.Quote)
+	pair := base + quote
+	names := []string{pair, "X" + base + "Z" + quote, "X" + base + "X" + quote}
+	return pair, names, nil
+}
+
+func (s *KrakenSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	prices, err := s.FetchPrices(ctx, []Feed{feed})
+	if err != nil {
+		return nil, err
+	}
+	if price, ok := prices[feed.Key]; ok {
+		return price, nil
+	}
+	pair, _, _ := s.pair(feed)
+	return nil, fmt.Errorf("pair %s not found", pair)
+}
+
+// FetchPrices quotes all the feeds with one call, the ticker takes comma-separated pairs
+// Kraken rejects the whole request when one pair is unknown, feeds then fall back
+// to one request each
+func (s *KrakenSource) FetchPrices(ctx context.Context, feeds []Feed) (map[string]*big.Rat, error) {
+	var pairs []string
+	names := make(map[string][]string) // Name in the response -> feed keys
+	for _, feed := range feeds {
+		pair, aliases, err := s.pair(feed)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(pairs, pair) {
+			pairs = append(pairs, pair)
+		}
+		for _, name := range aliases {
+			names[name] = append(names[name], feed.Key)
+		}
+	}
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = krakenAPIURL
+	}
+	url := fmt.Sprintf("%s/0/public/Ticker?pair=%s", strings.TrimSuffix(baseURL, "/"), neturl.QueryEscape(strings.Join(pairs, ",")))
+
+	var result struct {
+		Error  []string `json:"error"`
+		Result map[string]struct {
+			// Last trade closed: [price, lot volume]
+			C []string `json:"c"`
+		} `json:"result"`
+	}
+	if err := getJSON(ctx, url, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Error) > 0 {
+		return nil, fmt.Errorf("kraken error: %s", strings.Join(result.Error, ", "))
+	}
+
+	prices := make(map[string]*big.Rat, len(feeds))
+	for name, ticker := range result.Result {
+		keys := names[strings.ToUpper(name)]
+		if len(keys) == 0 && len(pairs) == 1 {
+			keys = feedKeys(feeds) // A single pair may be answered under any name
+		}
+		if len(keys) == 0 || len(ticker.C) == 0 {
+			continue
+		}
+		price, err := parseDecimal(ticker.C[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", name, err)
+		}
+		for _, key := range keys {
+			prices[key] = price
+		}
+	}
+	return prices, nil
+}
+
+// CoinbaseSource queries the Coinbase spot price
+// It does not implement BatchPriceSource: the spot endpoint takes a single pair and
+// Coinbase has no public one quoting several, so each feed costs one request
+type CoinbaseSource struct {
+	Symbol string
+}
+
+func (s *CoinbaseSource) Name() string { return "coinbase" }
+
+func (s *CoinbaseSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	pair := s.Symbol
+	if pair == "" {
+		base, err := feedBase(feed)
+		if err != nil {
+			return nil, err
+		}
+		pair = base + "-" + feed.Quote
+	}
+	url := fmt.Sprintf("https://api.coinbase.com/v2/prices/%s/spot", pair)
+
+	var result struct {
+		Data struct {
+			Amount string `json:"amount"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, url, nil, &result); err != nil {
+		return nil, err
+	}
+	return parseDecimal(result.Data.Amount)
+}
+
+// JSONSource queries any HTTP endpoint and reads the price at a dot-separated path
+// {coin}, {symbol} and {quote} are replaced in both with the CoinGecko ID, the base
+// ticker and the lowercase quote currency of the feed
+// Example: URL "https://example.com/ticker/{symbol}", Path "data.prices.0.{quote}"
+type JSONSource struct {
+	SourceName string // Tells json sources apart in logs and metrics (ex: json:example.com)
+	URL        string
+	Path       string
+	Symbol     string
+	APIKey     string
+}
+
+func (s *JSONSource) Name() string { return s.SourceName }
+
+func (s *JSONSource) FetchPrice(ctx context.Context, feed Feed) (*big.Rat, error) {
+	replacer := strings.NewReplacer("{coin}", feed.Coin, "{quote}", strings.ToLower(feed.Quote))
+	url := replacer.Replace(s.URL)
+	if strings.Contains(url, "{symbol}") {
+		symbol := s.Symbol
+		if symbol == "" {
+			base, err := feedBase(feed)
+			if err != nil {
+				return nil, err
+			}
+			symbol = base
+		}
+		url = strings.ReplaceAll(url, "{symbol}", symbol)
+	}
+
+	headers := map[string]string{}
+	if s.APIKey != "" {
+		headers["Authorization"] = "Bearer " + s.APIKey
+	}
+
+	var result interface{}
+	if err := getJSON(ctx, url, headers, &result); err != nil {
+		return nil, err
+	}
+
+	value, err := lookupJSONPath(result, replacer.Replace(s.Path))
+	if err != nil {
+		return nil, err
+	}
+	return parsePrice(value)
+}
+
+// Walk a decoded JSON document following a dot-separated path of keys and array indexes
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	if path == "" {
+		return current, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found in response", key)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("invalid array index %q in path", key)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("cannot read %q from a %T", key, current)
+		}
+	}
+	return current, nil
+}
+
+// NewPriceSource builds a price source from its configuration
+func NewPriceSource(cfg SourceConfig) (PriceSource, error) {
+	if cfg.Name != "" && !strings.EqualFold(cfg.Type, "json") {
+		return nil, fmt.Errorf("name is only supported by json sources, %s is named after its type", cfg.Type)
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "coingecko":
+		return &CoinGeckoSource{APIKey: cfg.APIKey, BaseURL: cfg.URL}, nil
+	case "binance":
+		return &BinanceSource{Symbol: cfg.Symbol}, nil
+	case "kraken":
+		return &KrakenSource{Symbol: cfg.Symbol, BaseURL: cfg.URL}, nil
+	case "coinbase":
+		return &CoinbaseSource{Symbol: cfg.Symbol}, nil
+	case "json":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("json source requires a URL")
+		}
+		name := cfg.Name
+		if name == "" {
+			u, err := neturl.Parse(cfg.URL)
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("json source URL %q has no host", cfg.URL)
+			}
+			name = "json:" + u.Host
+		}
+		return &JSONSource{SourceName: name, URL: cfg.URL, Path: cfg.Path, Symbol: cfg.Symbol, APIKey: cfg.APIKey}, nil
+	}
+	return nil, fmt.Errorf("unknown price source %q", cfg.Type)
+}
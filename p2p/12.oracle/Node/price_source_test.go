@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CoinGecko source of a mock, throttled by its own fetcher so tests share no cache
+func mockSource(m *mockCoinGecko) PriceSource {
+	cfg := SourceConfig{Type: "coingecko", URL: m.URL, RateLimit: 100}
+	return NewFetcher(time.Minute).Wrap(&CoinGeckoSource{BaseURL: m.URL}, cfg, nil)
+}
+
+// A json source reading the simple/price answer of a mock, to price a feed from two servers
+func mockJSONSource(m *mockCoinGecko) SourceConfig {
+	return SourceConfig{Type: "json", URL: m.URL + "/simple/price?ids={coin}&vs_currencies={quote}", Path: "{coin}.{quote}"}
+}
+
+func TestCoinGeckoBatchIsExact(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000.123456785")
+	coingecko.SetPrice("bitcoin", "eur", "0.29")
+	source := mockSource(coingecko).(*fetchedSource)
+
+	feeds := []Feed{ethereumFeed, {Key: "BTC/EUR", Coin: "bitcoin", Base: "BTC", Quote: "EUR", Decimals: defaultDecimals}}
+	prices, err := source.FetchPrices(testContext(t), feeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatDecimal(prices["ethereum"]); got != "3000.123456785" {
+		t.Errorf("ethereum = %s, want 3000.123456785", got)
+	}
+	if got := feeds[1].ToChain(prices["BTC/EUR"]).String(); got != "29000000" {
+		t.Errorf("BTC/EUR on chain = %s, want 29000000", got)
+	}
+
+	// Both feeds came in one request, and are now cached
+	for _, feed := range feeds {
+		if _, err := source.FetchPrice(testContext(t), feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests := coingecko.Requests(); requests != 1 {
+		t.Errorf("%d requests, want 1", requests)
+	}
+}
+
+func TestKrakenBatch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("pair"))
+		// Legacy pairs are renamed in the answer, newer ones are not
+		w.Write([]byte(`{"error":[],"result":{
+			"XETHZUSD":{"c":["3000.12","0.1"]},
+			"XXBTZEUR":{"c":["60000.5","0.2"]},
+			"SOLUSD":{"c":["150.25","3"]}}}`))
+	}))
+	t.Cleanup(server.Close)
+	source := NewFetcher(time.Minute).Wrap(&KrakenSource{BaseURL: server.URL},
+		SourceConfig{Type: "kraken", URL: server.URL, RateLimit: 100}, nil).(*fetchedSource)
+
+	feeds := []Feed{
+		ethereumFeed,
+		{Key: "BTC/EUR", Coin: "bitcoin", Base: "BTC", Quote: "EUR", Decimals: defaultDecimals},
+		{Key: "SOL/USD", Coin: "solana", Base: "SOL", Quote: "USD", Decimals: defaultDecimals},
+	}
+	if !source.Batchable() {
+		t.Fatal("kraken source is not batchable")
+	}
+	if _, err := source.FetchPrices(testContext(t), feeds); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ethereum": "3000.12", "BTC/EUR": "60000.5", "SOL/USD": "150.25"}
+	for _, feed := range feeds {
+		price, err := source.FetchPrice(testContext(t), feed)
+		if err != nil {
+			t.Fatalf("%s: %v", feed, err)
+		}
+		if got := formatDecimal(price); got != want[feed.Key] {
+			t.Errorf("%s = %s, want %s", feed, got, want[feed.Key])
+		}
+	}
+	if len(requests) != 1 || requests[0] != "ETHUSD,XBTEUR,SOLUSD" {
+		t.Errorf("requests = %q, want one for ETHUSD,XBTEUR,SOLUSD", requests)
+	}
+}
+
+func TestFetchMetricsSkipCacheHits(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	coingecko.SetPrice("bitcoin", "usd", "60000")
+	metrics := NewMetrics("test", nil)
+	cfg := SourceConfig{Type: "coingecko", URL: coingecko.URL, RateLimit: 100}
+	source := NewFetcher(time.Minute).Wrap(&CoinGeckoSource{BaseURL: coingecko.URL}, cfg, metrics).(*fetchedSource)
+
+	bitcoinFeed := Feed{Key: "bitcoin", Coin: "bitcoin", Base: "BTC", Quote: "USD", Decimals: defaultDecimals}
+	if _, err := source.FetchPrices(testContext(t), []Feed{ethereumFeed, bitcoinFeed}); err != nil {
+		t.Fatal(err)
+	}
+	for _, feed := range []Feed{ethereumFeed, bitcoinFeed} {
+		if _, err := source.FetchPrice(testContext(t), feed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One request reached CoinGecko, the cache hits are not observed
+	var histogram dto.Metric
+	if err := metrics.fetchDuration.WithLabelValues("coingecko").(prometheus.Histogram).Write(&histogram); err != nil {
+		t.Fatal(err)
+	}
+	if count := histogram.GetHistogram().GetSampleCount(); count != 1 {
+		t.Errorf("%d fetches observed, want 1", count)
+	}
+}
+
+func TestCoinGeckoRetriesRateLimits(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	coingecko.FailNext("1", http.StatusTooManyRequests)
+
+	start := time.Now()
+	price, err := mockSource(coingecko).FetchPrice(testContext(t), ethereumFeed)
+	if err != nil {
+		t.Fatalf("FetchPrice after a 429: %v", err)
+	}
+	if formatDecimal(price) != "3000" {
+		t.Errorf("price = %s, want 3000", formatDecimal(price))
+	}
+	if requests := coingecko.Requests(); requests != 2 {
+		t.Errorf("%d requests, want 2", requests)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, before the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestCoinGeckoCapsRetryAfter(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.FailNext("86400", http.StatusTooManyRequests)
+	fetcher := NewFetcher(time.Minute)
+	source := fetcher.Wrap(&CoinGeckoSource{BaseURL: coingecko.URL}, SourceConfig{Type: "coingecko", URL: coingecko.URL, RateLimit: 100}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := source.FetchPrice(ctx, ethereumFeed); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FetchPrice = %v, want the deadline of the caller", err)
+	}
+	limiter := source.(*fetchedSource).limiter
+	limiter.mu.Lock()
+	pause := time.Until(limiter.blockedUntil)
+	limiter.mu.Unlock()
+	if pause > retryMaxBackoff {
+		t.Errorf("provider blocked for %s, want at most %s", pause, retryMaxBackoff)
+	}
+
+	// Closing the fetcher ends the retry the caller gave up on, instead of a 30s wait
+	fetcher.Close()
+	start := time.Now()
+	_, err := source.FetchPrice(testContext(t), ethereumFeed)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) && !errors.Is(err, context.Canceled) {
+		t.Fatalf("FetchPrice after Close = %v, want the 429 of the cancelled retry or a cancellation", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FetchPrice after Close took %s", elapsed)
+	}
+}
+
+func TestCoinGeckoDoesNotRetryClientErrors(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.FailNext("", http.StatusNotFound)
+
+	_, err := mockSource(coingecko).FetchPrice(testContext(t), ethereumFeed)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("FetchPrice = %v, want a 404 status error", err)
+	}
+	if requests := coingecko.Requests(); requests != 1 {
+		t.Errorf("%d requests, want 1", requests)
+	}
+}
+
+func TestCoinGeckoSlowAnswer(t *testing.T) {
+	coingecko := newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	coingecko.SetLatency(500 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := mockSource(coingecko).FetchPrice(ctx, ethereumFeed); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FetchPrice = %v, want the deadline of the caller", err)
+	}
+}
+
+func TestQuotePriceAcrossSources(t *testing.T) {
+	chain := newTestChain(t, 2)
+
+	coingecko, other := newMockCoinGecko(t), newMockCoinGecko(t)
+	coingecko.SetPrice("ethereum", "usd", "3000")
+	other.SetPrice("ethereum", "usd", "3001")
+
+	cfg := chain.nodeConfig(0, coingecko)
+	cfg.Sources["ethereum"] = append(cfg.Sources["ethereum"], mockJSONSource(other))
+	node := chain.newNode(t, cfg)
+	aggregated, _, err := node.quotePrice(testContext(t), ethereumFeed, node.sources["ethereum"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatDecimal(aggregated.Price); got != "3000.5" || len(aggregated.Sources) != 2 {
+		t.Errorf("price = %s from %v, want the median 3000.5 of both sources", got, aggregated.Sources)
+	}
+
+	// One source down: the other one is enough with min_sources 1, not with 2
+	broken := newMockCoinGecko(t)
+	broken.FailNext("", http.StatusNotFound)
+	cfg = chain.nodeConfig(1, coingecko)
+	brokenSource := mockJSONSource(broken)
+	brokenSource.Name = "broken"
+	cfg.Sources["ethereum"] = append(cfg.Sources["ethereum"], brokenSource)
+	node = chain.newNode(t, cfg)
+	aggregated, errs, err := node.quotePrice(testContext(t), ethereumFeed, node.sources["ethereum"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggregated.Failed) != 1 || aggregated.Failed[0] != "broken" || errs["broken"] == nil {
+		t.Errorf("failed sources = %v, want [broken]", aggregated.Failed)
+	}
+	if got := formatDecimal(aggregated.Price); got != "3000" {
+		t.Errorf("price = %s, want 3000 from coingecko alone", got)
+	}
+
+	broken.FailNext("", http.StatusNotFound)
+	node.config.MinSources = 2
+	if _, _, err := node.quotePrice(testContext(t), ethereumFeed, node.sources["ethereum"]); err == nil {
+		t.Errorf("quotePrice with min_sources 2 and one source down succeeded")
+	}
+}
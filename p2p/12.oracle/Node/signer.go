@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Chain ID of Anvil and Hardhat local networks, the only place default keys are allowed
+const localChainID = 31337
+
+// Anvil default private keys (first 10 accounts), public knowledge
+var anvilPrivateKeys = []string{
+	"ac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80", // Account 0
+	"59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d", // Account 1
+	"5de4111afa1a4b94908f83103eb1f1706367c2e68ca870fc3fb9a804cdab365a", // Account 2
+	"7c852118294e51e653712a81e05800f419141751be58f605c371e15141b007a6", // Account 3
+	"47e179ec197488593b187f80a00eb0da91f1b9d0b13f8733639f19c30a34926a", // Account 4
+	"8b3a350cf5c34c9194ca85829a2df0ec3153be0318b5e2d3348e872092edffba", // Account 5
+	"92db14e403b83dfe3df233f83dfa3a0d7096f21ca9b0d6d6b8d88b2b4ec1564e", // Account 6
+	"4bbbf85ce3377467afe5d46f804f221813b2bb87f24d81f60f1fcdbf7cbf4356", // Account 7
+	"dbda1821b80551c9d65939329250298aa3472ba22feea921c0cf5d620ea67b97", // Account 8
+	"2a871d0798f97d79848a013d4936a73bf4cc922c825d33c1cf7073dff6d409c6", // Account 9
+}
+
+// Tell whether an address belongs to one of the well-known Anvil accounts
+func isAnvilAccount(address common.Address) bool {
+	for _, hexKey := range anvilPrivateKeys {
+		key, err := crypto.HexToECDSA(hexKey)
+		if err != nil {
+			continue
+		}
+		if crypto.PubkeyToAddress(key.PublicKey) == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Load the account a node signs with: a raw key, a keystore file or an external signer
+// closeSigner releases the connection of an external signer, it does nothing for local keys
+func loadSigner(cfg *Config, chainID *big.Int) (address common.Address, signer bind.SignerFn, closeSigner func(), err error) {
+	closeSigner = func() {}
+	switch {
+	case cfg.ExternalSigner != "":
+		address = common.HexToAddress(cfg.SignerAddress)
+		var external *externalSigner
+		if external, err = newExternalSigner(cfg.ExternalSigner, cfg.ExternalSignerMethod, address, chainID); err == nil {
+			signer, closeSigner = external.SignTx, external.Close
+		}
+	case cfg.Keystore != "":
+		var key *ecdsa.PrivateKey
+		if key, err = loadKeystore(cfg.Keystore, cfg.PassphraseFile); err == nil {
+			address, signer, err = keyedSigner(key, chainID)
+		}
+	default:
+		var key *ecdsa.PrivateKey
+		if key, err = crypto.HexToECDSA(cfg.PrivateKey); err != nil {
+			return common.Address{}, nil, nil, fmt.Errorf("invalid private key: %w", err)
+		}
+		address, signer, err = keyedSigner(key, chainID)
+	}
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	if chainID.Cmp(big.NewInt(localChainID)) != 0 && isAnvilAccount(address) {
+		closeSigner()
+		return common.Address{}, nil, nil, fmt.Errorf("refusing to use well-known Anvil account %s on chain %s (only allowed on chain %d)",
+			address.Hex(), chainID, localChainID)
+	}
+	return address, signer, closeSigner, nil
+}
+
+// Signer of an in-memory private key
+func keyedSigner(key *ecdsa.PrivateKey, chainID *big.Int) (common.Address, bind.SignerFn, error) {
+	auth, err := bind.NewKeyedTransactorWithChainID(key, chainID)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to create transactor: %w", err)
+	}
+	return auth.From, auth.Signer, nil
+}
+
+// Decrypt a go-ethereum keystore JSON file with the passphrase stored in another file
+func loadKeystore(path, passphraseFile string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	passphrase, err := os.ReadFile(passphraseFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, strings.TrimRight(string(passphrase), "\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %s: %w", path, err)
+	}
+	return key.PrivateKey, nil
+}
+
+// Timeout of one signing request, Clef may wait for a human to approve it
+const externalSignerTimeout = 2 * time.Minute
+
+// externalSigner delegates signing to a remote JSON-RPC signer. The default
+// method is eth_signTransaction; Clef exposes the same call as account_signTransaction.
+type externalSigner struct {
+	client  *rpc.Client
+	method  string
+	address common.Address
+	chainID *big.Int
+}
+
+// Connect to a remote signer holding the key of address
+func newExternalSigner(endpoint, method string, address common.Address, chainID *big.Int) (*externalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to external signer: %w", err)
+	}
+	if method == "" {
+		method = "eth_signTransaction"
+	}
+	return &externalSigner{client: client, method: method, address: address, chainID: chainID}, nil
+}
+
+// Close the connection to the signer
+func (s *externalSigner) Close() {
+	s.client.Close()
+}
+
+// SignTx is the bind.SignerFn of the remote key
+func (s *externalSigner) SignTx(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	if from != s.address {
+		return nil, bind.ErrNotAuthorized
+	}
+
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:    common.NewMixedcaseAddress(from),
+		Nonce:   hexutil.Uint64(tx.Nonce()),
+		Value:   hexutil.Big(*tx.Value()),
+		Gas:     hexutil.Uint64(tx.Gas()),
+		Input:   &data,
+		ChainID: (*hexutil.Big)(s.chainID),
+	}
+	if tx.To() != nil {
+		to := common.NewMixedcaseAddress(*tx.To())
+		args.To = &to
+	}
+	if tx.Type() == types.LegacyTxType {
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	} else {
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignerTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+	if err := s.client.CallContext(ctx, &result, s.method, &args); err != nil {
+		return nil, fmt.Errorf("external signer: %w", err)
+	}
+	raw, err := decodeSignedTx(result)
+	if err != nil {
+		return nil, fmt.Errorf("external signer: %w", err)
+	}
+
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("external signer returned an invalid transaction: %w", err)
+	}
+
+	// Never broadcast something else than what was asked for
+	sender, err := types.Sender(types.LatestSignerForChainID(s.chainID), signed)
+	if err != nil || sender != from {
+		return nil, fmt.Errorf("external signer signed with %s instead of %s", sender.Hex(), from.Hex())
+	}
+	if err := sameTransaction(signed, tx, s.chainID); err != nil {
+		return nil, fmt.Errorf("external signer returned a different transaction: %w", err)
+	}
+	return signed, nil
+}
+
+// Check a signed transaction carries every field of the request, on the chain of the node
+func sameTransaction(signed, requested *types.Transaction, chainID *big.Int) error {
+	sameTo := signed.To() == nil && requested.To() == nil ||
+		signed.To() != nil && requested.To() != nil && *signed.To() == *requested.To()
+	sameAccessList := len(signed.AccessList()) == 0 && len(requested.AccessList()) == 0 ||
+		reflect.DeepEqual(signed.AccessList(), requested.AccessList())
+
+	switch {
+	case signed.Type() != requested.Type():
+		return fmt.Errorf("type %d instead of %d", signed.Type(), requested.Type())
+	case signed.ChainId().Cmp(chainID) != 0:
+		return fmt.Errorf("chain ID %s instead of %s", signed.ChainId(), chainID)
+	case signed.Nonce() != requested.Nonce():
+		return fmt.Errorf("nonce %d instead of %d", signed.Nonce(), requested.Nonce())
+	case signed.Gas() != requested.Gas():
+		return fmt.Errorf("gas %d instead of %d", signed.Gas(), requested.Gas())
+	case signed.GasFeeCap().Cmp(requested.GasFeeCap()) != 0:
+		return fmt.Errorf("fee cap %s instead of %s", signed.GasFeeCap(), requested.GasFeeCap())
+	case signed.GasTipCap().Cmp(requested.GasTipCap()) != 0:
+		return fmt.Errorf("tip cap %s instead of %s", signed.GasTipCap(), requested.GasTipCap())
+	case signed.Value().Cmp(requested.Value()) != 0:
+		return fmt.Errorf("value %s instead of %s", signed.Value(), requested.Value())
+	case !sameTo:
+		return errors.New("different recipient")
+	case !bytes.Equal(signed.Data(), requested.Data()):
+		return errors.New("different data")
+	case !sameAccessList:
+		return errors.New("different access list")
+	}
+	return nil
+}
+
+// Signers answer either the raw transaction hex or Clef's {"raw": ..., "tx": ...}
+func decodeSignedTx(result json.RawMessage) ([]byte, error) {
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err == nil {
+		return raw, nil
+	}
+
+	var response struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil || len(response.Raw) == 0 {
+		return nil, fmt.Errorf("unexpected response %s", string(result))
+	}
+	return response.Raw, nil
+}
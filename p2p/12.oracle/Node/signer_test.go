@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Remote signer holding key, tamper alters each request before signing it
+func newMockSigner(t *testing.T, key *ecdsa.PrivateKey, tamper func(*types.DynamicFeeTx)) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ID     json.RawMessage       `json:"id"`
+			Params []apitypes.SendTxArgs `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Params) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		args := request.Params[0]
+		tx := &types.DynamicFeeTx{
+			ChainID:   args.ChainID.ToInt(),
+			Nonce:     uint64(args.Nonce),
+			GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+			GasFeeCap: args.MaxFeePerGas.ToInt(),
+			Gas:       uint64(args.Gas),
+			Value:     args.Value.ToInt(),
+			Data:      *args.Input,
+		}
+		if args.To != nil {
+			to := args.To.Address()
+			tx.To = &to
+		}
+		tamper(tx)
+		signed, err := types.SignNewTx(key, types.LatestSignerForChainID(tx.ChainID), tx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		raw, _ := signed.MarshalBinary()
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": hexutil.Bytes(raw)})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestExternalSignerRejectsAlteredTransactions(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(simulatedChainID)
+	to := common.HexToAddress("0x5FbDB2315678afecb367f032d93F642f64180aa3")
+	request := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(30e9),
+		Gas:       100000,
+		To:        &to,
+		Value:     new(big.Int),
+		Data:      []byte{0x01, 0x02},
+	})
+
+	tests := []struct {
+		name   string
+		tamper func(*types.DynamicFeeTx)
+		want   string // Part of the error, empty when the transaction is accepted
+	}{
+		{"unchanged", func(*types.DynamicFeeTx) {}, ""},
+		{"value", func(tx *types.DynamicFeeTx) { tx.Value = big.NewInt(1) }, "value"},
+		{"fee cap", func(tx *types.DynamicFeeTx) { tx.GasFeeCap = big.NewInt(300e9) }, "fee cap"},
+		{"tip cap", func(tx *types.DynamicFeeTx) { tx.GasTipCap = big.NewInt(20e9) }, "tip cap"},
+		{"chain ID", func(tx *types.DynamicFeeTx) { tx.ChainID = big.NewInt(1) }, "signed with"},
+		{"nonce", func(tx *types.DynamicFeeTx) { tx.Nonce++ }, "nonce"},
+		{"recipient", func(tx *types.DynamicFeeTx) { tx.To = &common.Address{} }, "recipient"},
+		{"data", func(tx *types.DynamicFeeTx) { tx.Data = nil }, "data"},
+		{"access list", func(tx *types.DynamicFeeTx) { tx.AccessList = types.AccessList{{Address: to}} }, "access list"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer, err := newExternalSigner(newMockSigner(t, key, test.tamper), "", address, chainID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer signer.Close()
+
+			signed, err := signer.SignTx(address, request)
+			switch {
+			case test.name == "unchanged":
+				if err != nil {
+					t.Fatalf("SignTx: %v", err)
+				}
+				if signed.Hash() == request.Hash() {
+					t.Error("transaction returned unsigned")
+				}
+			case err == nil:
+				t.Fatalf("SignTx accepted a transaction with another %s", test.name)
+			case !strings.Contains(err.Error(), test.want):
+				t.Errorf("SignTx = %v, want an error about the %s", err, test.want)
+			}
+		})
+	}
+}
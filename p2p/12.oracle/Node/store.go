@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Kinds of history records
+const (
+	RecordPrice      = "price"      // Aggregated off-chain price
+	RecordSubmission = "submission" // Price submission transaction of this node
+	RecordRound      = "round"      // Round finalized on-chain
+)
+
+// Default and maximum number of records returned by /history
+const (
+	defaultHistoryLimit = 1000
+	maxHistoryLimit     = 10000
+)
+
+// HistoryRecord is one entry of the history of a coin
+// Prices are decimal strings with the decimals of the feed
+type HistoryRecord struct {
+	Kind string    `json:"kind"`
+	Coin string    `json:"coin"`
+	Time time.Time `json:"time"`
+
+	Price    string   `json:"price,omitempty"`
+	Sources  []string `json:"sources,omitempty"`
+	Rejected []string `json:"rejected,omitempty"`
+	Failed   []string `json:"failed,omitempty"`
+
+	RoundID string `json:"round_id,omitempty"`
+	TxHash  string `json:"tx_hash,omitempty"`
+	Status  string `json:"status,omitempty"` // success, reverted or failed
+	Error   string `json:"error,omitempty"`
+	GasUsed uint64 `json:"gas_used,omitempty"`
+	Block   uint64 `json:"block,omitempty"`
+
+	// Whether this node took part in a finalized round
+	Submitted *bool `json:"submitted,omitempty"`
+}
+
+// Store keeps the history of a node in a bbolt file, one bucket per coin
+// with records keyed by time so ranges are read with a cursor
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens or creates the database of a node
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	// Fail fast instead of hanging when another process holds the file
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close flushes and closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Key of a record: big-endian time then a sequence number, so keys sort chronologically
+func recordKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// Add appends a record to the history of its coin
+func (s *Store) Add(record HistoryRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Time = record.Time.UTC()
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(record.Coin))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(recordKey(record.Time, seq), value)
+	})
+}
+
+// Record adds a record and only logs failures, history must never block submissions
+// A nil store records nothing
+func (s *Store) Record(logger *slog.Logger, record HistoryRecord) {
+	if s == nil {
+		return
+	}
+	if err := s.Add(record); err != nil {
+		logger.Warn("Failed to store history record", "kind", record.Kind, "coin", record.Coin, "error", err)
+	}
+}
+
+// History returns the records of a coin between from and to (inclusive), oldest first
+// An empty kind returns every kind
+func (s *Store) History(coin, kind string, from, to time.Time, limit int) ([]HistoryRecord, error) {
+	records := []HistoryRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(coin))
+		if bucket == nil {
+			return nil
+		}
+
+		end := recordKey(to, ^uint64(0))
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(recordKey(from, 0)); key != nil && bytes.Compare(key, end) <= 0; key, value = cursor.Next() {
+			var record HistoryRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("corrupt record %x: %w", key, err)
+			}
+			if kind != "" && record.Kind != kind {
+				continue
+			}
+			records = append(records, record)
+			if len(records) >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Last returns the latest record of a kind for a coin, nil when there is none
+// A nil store has no records
+func (s *Store) Last(coin, kind string) (*HistoryRecord, error) {
+	if s == nil {
+		return nil, nil
+	}
+	var last *HistoryRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(coin))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var record HistoryRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("corrupt record %x: %w", key, err)
+			}
+			if record.Kind == kind {
+				last = &record
+				return nil
+			}
+		}
+		return nil
+	})
+	return last, err
+}
+
+// Parse a time given as RFC 3339 or unix seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Serve /history?coin=&from=&to=&kind=&limit=
+// from and to are RFC 3339 times or unix seconds, the last 24 hours by default
+func (s *Store) historyHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	coin := query.Get("coin")
+	if coin == "" {
+		http.Error(w, "Missing 'coin' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = parseTime(value); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid 'from': %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = parseTime(value); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid 'to': %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if from.After(to) {
+		http.Error(w, "'from' is after 'to'", http.StatusBadRequest)
+		return
+	}
+
+	kind := query.Get("kind")
+	if kind != "" && kind != RecordPrice && kind != RecordSubmission && kind != RecordRound {
+		http.Error(w, fmt.Sprintf("Invalid 'kind', expected %s, %s or %s", RecordPrice, RecordSubmission, RecordRound), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxHistoryLimit {
+			http.Error(w, fmt.Sprintf("Invalid 'limit', expected 1 to %d", maxHistoryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := s.History(coin, kind, from, to, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read history: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"coin":    coin,
+		"from":    from.UTC(),
+		"to":      to.UTC(),
+		"records": records,
+	})
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// How often pending transactions are checked for a receipt
+const receiptPollInterval = 1 * time.Second
+
+// Minimum fee increase accepted by geth-like txpools to replace a transaction
+const minReplacementBumpPercent = 10
+
+// GasSettings controls how the transaction manager prices and sizes transactions
+type GasSettings struct {
+	// Extra gas added on top of EstimateGas, in percent
+	GasLimitMarginPercent uint64
+
+	// Fee cap never exceeded, even when bumping (nil means no limit)
+	MaxFeeCap *big.Int
+
+	// Blocks a transaction may stay pending before it is replaced with higher fees
+	StuckTxBlocks uint64
+
+	// Fee increase of a replacement transaction, in percent
+	FeeBumpPercent uint64
+}
+
+// TxManager sends every transaction of one key. It hands out nonces locally so
+// concurrent submissions do not collide, resyncs them from the chain after an
+// error, and tracks receipts in the background, replacing stuck transactions
+// with bumped fees.
+type TxManager struct {
+	client  ChainBackend
+	address common.Address
+	signer  bind.SignerFn
+	chainID *big.Int
+	gas     GasSettings
+	logger  *slog.Logger
+
+	mu          sync.Mutex
+	nonce       uint64
+	nonceSynced bool
+	pending     map[uint64]*PendingTx // Keyed by nonce, replacements keep the same entry
+}
+
+// PendingTx is a sent transaction waiting for its receipt
+type PendingTx struct {
+	Label string
+
+	mu        sync.Mutex
+	tx        *types.Transaction // Latest version, replaced when fees are bumped
+	hashes    []common.Hash      // Every hash broadcast for this nonce, any of them may be mined
+	sentBlock uint64
+
+	done    chan struct{}
+	receipt *types.Receipt
+	err     error
+}
+
+// Tx returns the latest version of the transaction, a replacement once fees were bumped
+func (p *PendingTx) Tx() *types.Transaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tx
+}
+
+// Latest version of the transaction, every hash sent and the block of the last send
+func (p *PendingTx) state() (*types.Transaction, []common.Hash, uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tx, append([]common.Hash(nil), p.hashes...), p.sentBlock
+}
+
+// Record a replacement sent at blockNumber
+func (p *PendingTx) replace(tx *types.Transaction, blockNumber uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tx = tx
+	p.hashes = append(p.hashes, tx.Hash())
+	p.sentBlock = blockNumber
+}
+
+// Wait blocks until the transaction is mined or the context is done
+func (p *PendingTx) Wait(ctx context.Context) (*types.Receipt, error) {
+	select {
+	case <-p.done:
+		return p.receipt, p.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *PendingTx) resolve(receipt *types.Receipt, err error) {
+	p.receipt = receipt
+	p.err = err
+	close(p.done)
+}
+
+// NewTxManager creates the transaction manager of an account
+func NewTxManager(client ChainBackend, address common.Address, chainID *big.Int, signer bind.SignerFn, gas GasSettings, logger *slog.Logger) *TxManager {
+	return &TxManager{
+		client:  client,
+		address: address,
+		signer:  signer,
+		chainID: chainID,
+		gas:     gas,
+		logger:  logger,
+		pending: make(map[uint64]*PendingTx),
+	}
+}
+
+// Nonce returns the next nonce the manager will use
+func (m *TxManager) Nonce() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nonce
+}
+
+// Fees of a new transaction: tip from the node, cap at twice the base fee plus the tip
+// Returns a nil tip on chains without EIP-1559, the cap is then the legacy gas price
+func (m *TxManager) suggestFees(ctx context.Context) (tip, feeCap *big.Int, err error) {
+	head, err := m.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get latest header: %w", classifyError(err))
+	}
+
+	if head.BaseFee == nil {
+		gasPrice, err := m.client.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to suggest gas price: %w", classifyError(err))
+		}
+		return nil, m.capFee(gasPrice), nil
+	}
+
+	tip, err = m.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to suggest gas tip cap: %w", classifyError(err))
+	}
+	feeCap = new(big.Int).Mul(head.BaseFee, big.NewInt(2))
+	feeCap.Add(feeCap, tip)
+	feeCap = m.capFee(feeCap)
+
+	if feeCap.Cmp(head.BaseFee) < 0 {
+		return nil, nil, fmt.Errorf("base fee %s gwei is above the max fee cap of %s gwei",
+			weiToGwei(head.BaseFee), weiToGwei(m.gas.MaxFeeCap))
+	}
+	if tip.Cmp(feeCap) > 0 {
+		tip = new(big.Int).Set(feeCap)
+	}
+	return tip, feeCap, nil
+}
+
+// Limit a fee to the configured max fee cap
+func (m *TxManager) capFee(fee *big.Int) *big.Int {
+	if m.gas.MaxFeeCap != nil && fee.Cmp(m.gas.MaxFeeCap) > 0 {
+		return new(big.Int).Set(m.gas.MaxFeeCap)
+	}
+	return fee
+}
+
+// Build a dynamic-fee transaction, or a legacy one when tip is nil
+func (m *TxManager) newTx(nonce uint64, to *common.Address, data []byte, gas uint64, tip, feeCap *big.Int) *types.Transaction {
+	if tip == nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       to,
+			Value:    big.NewInt(0),
+			Gas:      gas,
+			GasPrice: feeCap,
+			Data:     data,
+		})
+	}
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   m.chainID,
+		Nonce:     nonce,
+		To:        to,
+		Value:     big.NewInt(0),
+		Gas:       gas,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Data:      data,
+	})
+}
+
+// Send signs and broadcasts a contract call with the next local nonce
+func (m *TxManager) Send(ctx context.Context, label string, to common.Address, data []byte) (*PendingTx, error) {
+	// Estimate gas first: a call that would revert never costs anything
+	estimated, err := m.client.EstimateGas(ctx, ethereum.CallMsg{From: m.address, To: &to, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate gas: %w", classifyError(err))
+	}
+	gasLimit := estimated * (100 + m.gas.GasLimitMarginPercent) / 100
+
+	tip, feeCap, err := m.suggestFees(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := m.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block number: %w", classifyError(err))
+	}
+
+	// The nonce is reserved under the lock, signing and sending are not: a remote
+	// signer may take minutes and must not hold the other submissions and checkPending
+	var signedTx *types.Transaction
+	for attempt := 0; ; attempt++ {
+		nonce, err := m.reserveNonce(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		signedTx, err = m.signer(m.address, m.newTx(nonce, &to, data, gasLimit, tip, feeCap))
+		if err != nil {
+			m.releaseNonce()
+			return nil, fmt.Errorf("failed to sign transaction: %w", err)
+		}
+
+		err = classifyError(m.client.SendTransaction(ctx, signedTx))
+		if err == nil {
+			break
+		}
+		// The local nonce may be wrong (tx sent elsewhere, dropped tx...), ask the chain next time
+		m.releaseNonce()
+		// Another sender used the nonce: resync and send again right away, once
+		if !errors.Is(err, ErrNonceTooLow) || attempt > 0 {
+			return nil, fmt.Errorf("failed to send transaction: %w", err)
+		}
+	}
+
+	pending := &PendingTx{
+		Label:     label,
+		tx:        signedTx,
+		hashes:    []common.Hash{signedTx.Hash()},
+		sentBlock: blockNumber,
+		done:      make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.pending[signedTx.Nonce()] = pending
+	m.mu.Unlock()
+	return pending, nil
+}
+
+// Reserve the next nonce, read from the chain when the local one may be wrong
+// The chain is queried without the lock, only the reservation holds it
+func (m *TxManager) reserveNonce(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	synced := m.nonceSynced
+	m.mu.Unlock()
+
+	var chainNonce uint64
+	if !synced {
+		var err error
+		if chainNonce, err = m.client.PendingNonceAt(ctx, m.address); err != nil {
+			return 0, fmt.Errorf("failed to get nonce: %w", classifyError(err))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A concurrent reservation may have synced the nonce in the meantime
+	if !m.nonceSynced {
+		m.nonce = chainNonce
+		m.nonceSynced = true
+	}
+	nonce := m.nonce
+	m.nonce++
+	return nonce, nil
+}
+
+// Give up a reserved nonce that was not broadcast, the next reservation asks the
+// chain so no gap is left
+func (m *TxManager) releaseNonce() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nonceSynced = false
+}
+
+// RevertError explains a transaction mined with status 0: it is replayed with
+// eth_call on the state of its block and the reason of the contract is decoded
+// The reason may be missing when the state no longer makes the call fail
+func (m *TxManager) RevertError(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) *RevertError {
+	msg := ethereum.CallMsg{From: m.address, To: tx.To(), Gas: tx.Gas(), Value: tx.Value(), Data: tx.Data()}
+	_, err := m.client.CallContract(ctx, msg, receipt.BlockNumber)
+
+	var revert *RevertError
+	switch err = classifyError(err); {
+	case errors.As(err, &revert):
+		return revert
+	case err == nil:
+		return &RevertError{}
+	case errors.Is(err, ErrRPCDown) || ctx.Err() != nil:
+		m.logger.Warn("Failed to replay reverted transaction", "tx_hash", receipt.TxHash.Hex(), "error", err)
+		return &RevertError{}
+	default:
+		// Execution failures without revert data, out of gas for instance
+		return &RevertError{Reason: err.Error()}
+	}
+}
+
+// Start tracks the receipts of pending transactions until the context is done
+func (m *TxManager) Start(ctx context.Context) {
+	ticker := time.NewTicker(receiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			for nonce, pending := range m.pending {
+				pending.resolve(nil, ctx.Err())
+				delete(m.pending, nonce)
+			}
+			m.mu.Unlock()
+			return
+		case <-ticker.C:
+			m.checkPending(ctx)
+		}
+	}
+}
+
+// Look up the receipt of every pending transaction and bump the stuck ones
+func (m *TxManager) checkPending(ctx context.Context) {
+	m.mu.Lock()
+	pendings := make([]*PendingTx, 0, len(m.pending))
+	for _, pending := range m.pending {
+		pendings = append(pendings, pending)
+	}
+	m.mu.Unlock()
+
+	if len(pendings) == 0 {
+		return
+	}
+
+	blockNumber, err := m.client.BlockNumber(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to get block number", "error", err)
+		return
+	}
+	confirmedNonce, err := m.client.NonceAt(ctx, m.address, nil)
+	if err != nil {
+		m.logger.Warn("Failed to get confirmed nonce", "error", err)
+		return
+	}
+
+	for _, pending := range pendings {
+		tx, hashes, sentBlock := pending.state()
+		receipt, err := m.findReceipt(ctx, hashes)
+		if err != nil {
+			m.logger.Warn("Failed to get receipt", "tx_hash", tx.Hash().Hex(), "error", err)
+			continue
+		}
+
+		nonce := tx.Nonce()
+		switch {
+		case receipt != nil:
+			m.finish(nonce, receipt, nil)
+		case confirmedNonce > nonce:
+			// The nonce was used by a transaction we do not know about
+			m.finish(nonce, nil, fmt.Errorf("transaction with nonce %d was replaced or dropped", nonce))
+		case m.gas.StuckTxBlocks > 0 && blockNumber >= sentBlock+m.gas.StuckTxBlocks:
+			if err := m.bump(ctx, pending, tx, blockNumber); err != nil {
+				m.logger.Warn("Could not replace stuck transaction", "label", pending.Label,
+					"tx_hash", tx.Hash().Hex(), "error", err)
+			}
+		}
+	}
+}
+
+// Receipt of any of the hashes sent for a pending transaction, nil if none is mined
+func (m *TxManager) findReceipt(ctx context.Context, hashes []common.Hash) (*types.Receipt, error) {
+	for _, hash := range hashes {
+		receipt, err := m.client.TransactionReceipt(ctx, hash)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return receipt, nil
+	}
+	return nil, nil
+}
+
+func (m *TxManager) finish(nonce uint64, receipt *types.Receipt, err error) {
+	m.mu.Lock()
+	pending, ok := m.pending[nonce]
+	delete(m.pending, nonce)
+	m.mu.Unlock()
+	if ok {
+		pending.resolve(receipt, err)
+	}
+}
+
+// Bump a fee by the configured percentage, at least to the given floor
+func (m *TxManager) bumpFee(fee, floor *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(int64(100+m.gas.FeeBumpPercent)))
+	bumped.Quo(bumped, big.NewInt(100))
+	if floor != nil && bumped.Cmp(floor) < 0 {
+		bumped.Set(floor)
+	}
+	return bumped
+}
+
+// Replace old, the stuck version of a pending transaction, with the same nonce and higher fees
+func (m *TxManager) bump(ctx context.Context, pending *PendingTx, old *types.Transaction, blockNumber uint64) error {
+	tip, feeCap, err := m.suggestFees(ctx)
+	if err != nil {
+		return err
+	}
+
+	var newTip, newFeeCap *big.Int
+	if old.Type() == types.LegacyTxType {
+		newFeeCap = m.capFee(m.bumpFee(old.GasPrice(), feeCap))
+	} else {
+		newTip = m.bumpFee(old.GasTipCap(), tip)
+		newFeeCap = m.capFee(m.bumpFee(old.GasFeeCap(), feeCap))
+		if newTip.Cmp(newFeeCap) > 0 {
+			newTip = new(big.Int).Set(newFeeCap)
+		}
+	}
+
+	// Txpools reject replacements that do not raise the fees enough
+	minFeeCap := new(big.Int).Mul(old.GasFeeCap(), big.NewInt(100+minReplacementBumpPercent))
+	minFeeCap.Quo(minFeeCap, big.NewInt(100))
+	if newFeeCap.Cmp(minFeeCap) < 0 {
+		return fmt.Errorf("max fee cap of %s gwei reached", weiToGwei(m.gas.MaxFeeCap))
+	}
+
+	signedTx, err := m.signer(m.address, m.newTx(old.Nonce(), old.To(), old.Data(), old.Gas(), newTip, newFeeCap))
+	if err != nil {
+		return fmt.Errorf("failed to sign replacement: %w", err)
+	}
+	if err := m.client.SendTransaction(ctx, signedTx); err != nil {
+		return fmt.Errorf("failed to send replacement: %w", classifyError(err))
+	}
+
+	pending.replace(signedTx, blockNumber)
+
+	m.logger.Warn("Replaced stuck transaction", "label", pending.Label, "stuck_blocks", m.gas.StuckTxBlocks,
+		"replaced_tx_hash", old.Hash().Hex(), "tx_hash", signedTx.Hash().Hex(), "fee_cap_gwei", weiToGwei(newFeeCap))
+	return nil
+}
+
+// Format a wei amount in gwei for logs
+func weiToGwei(wei *big.Int) string {
+	if wei == nil {
+		return "unlimited"
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Text('f', 2)
+}
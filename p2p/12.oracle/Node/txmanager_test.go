@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSlowSignerDoesNotBlockOtherTransactions(t *testing.T) {
+	ctx := testContext(t)
+	chain := newTestChain(t, 1)
+	key := chain.keys[0]
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(simulatedChainID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first signature waits for release, like a remote signer waiting for approval
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	signer := func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		first := false
+		once.Do(func() { first = true })
+		if first {
+			close(entered)
+			<-release
+		}
+		return opts.Signer(from, tx)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewTxManager(chain.client, address, big.NewInt(simulatedChainID), signer, GasSettings{GasLimitMarginPercent: 20}, logger)
+	go m.Start(ctx)
+
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	type sent struct {
+		pending *PendingTx
+		err     error
+	}
+	slow := make(chan sent, 1)
+	go func() {
+		pending, err := m.Send(ctx, "slow", to, nil)
+		slow <- sent{pending, err}
+	}()
+	<-entered
+
+	fast := make(chan sent, 1)
+	go func() {
+		pending, err := m.Send(ctx, "fast", to, nil)
+		fast <- sent{pending, err}
+	}()
+	var second sent
+	select {
+	case second = <-fast:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("a transaction waited for the signature of another one")
+	}
+	close(release)
+	first := <-slow
+	if first.err != nil || second.err != nil {
+		t.Fatalf("Send = %v, %v", first.err, second.err)
+	}
+	if first.pending.Tx().Nonce() != 0 || second.pending.Tx().Nonce() != 1 {
+		t.Errorf("nonces = %d, %d, want 0 then 1", first.pending.Tx().Nonce(), second.pending.Tx().Nonce())
+	}
+
+	for _, pending := range []*PendingTx{first.pending, second.pending} {
+		if receipt, err := pending.Wait(ctx); err != nil || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Errorf("%s: receipt = %v, %v", pending.Label, receipt, err)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RoundView is the local copy of the on-chain state of one coin, kept up to date from PriceUpdated events
+type RoundView struct {
+	RoundID        *big.Int  // Round currently collecting submissions
+	FinalizedRound *big.Int  // Last finalized round (nil until one is seen)
+	Price          *big.Int  // Finalized price of that round
+	LastUpdatedAt  time.Time // Block time of the finalization
+	Block          uint64    // Block of the finalization
+}
+
+// Watcher follows PriceUpdated events, over a websocket subscription when the
+// RPC supports it, by polling FilterLogs otherwise
+type Watcher struct {
+	client       ChainBackend
+	contract     *Oracle
+	address      common.Address
+	coins        map[common.Hash]string // Indexed coin topic -> feed key
+	feeds        map[string]Feed
+	pollInterval time.Duration
+	logger       *slog.Logger
+	metrics      *Metrics
+	store        *Store // History of finalized rounds, nil to keep none
+
+	mu        sync.Mutex
+	rounds    map[string]*RoundView
+	lastBlock uint64 // Last block with processed events, maybe not all of them
+}
+
+// NewWatcher creates a watcher for the given feeds, client should be a websocket
+// client for subscriptions to work
+func NewWatcher(client ChainBackend, contractAddress, address common.Address, feeds []Feed,
+	pollInterval time.Duration, logger *slog.Logger, metrics *Metrics) (*Watcher, error) {
+	contract, err := NewOracle(contractAddress, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate contract: %w", err)
+	}
+
+	topics := make(map[common.Hash]string, len(feeds))
+	byKey := make(map[string]Feed, len(feeds))
+	for _, feed := range feeds {
+		topics[crypto.Keccak256Hash([]byte(feed.Key))] = feed.Key
+		byKey[feed.Key] = feed
+	}
+
+	return &Watcher{
+		client:       client,
+		contract:     contract,
+		address:      address,
+		coins:        topics,
+		feeds:        byKey,
+		pollInterval: pollInterval,
+		logger:       logger,
+		metrics:      metrics,
+		rounds:       make(map[string]*RoundView),
+	}, nil
+}
+
+// Round returns a copy of the local view of a coin
+func (w *Watcher) Round(coin string) (RoundView, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	view, ok := w.rounds[coin]
+	if !ok {
+		return RoundView{}, false
+	}
+	return *view, true
+}
+
+// Names of the watched coins
+func (w *Watcher) coinNames() []string {
+	names := make([]string, 0, len(w.coins))
+	for _, coin := range w.coins {
+		names = append(names, coin)
+	}
+	return names
+}
+
+// Run follows events until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	if err := w.sync(ctx); err != nil {
+		w.logger.Warn("Watcher failed to read initial rounds", "error", err)
+	}
+
+	// Subscribe again with exponential backoff, reset once a subscription is up
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		subscribed, err := w.subscribe(ctx)
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			w.logger.Info("RPC does not support subscriptions, polling PriceUpdated events", "interval", w.pollInterval)
+			w.poll(ctx)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			attempt = 0
+		}
+		delay := retryBackoff(attempt)
+		w.logger.Warn("PriceUpdated subscription lost", "error", err, "retry_in", delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Read the current round of every coin from the contract
+func (w *Watcher) sync(ctx context.Context) error {
+	head, err := w.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(head)}
+
+	for _, coin := range w.coins {
+		round, err := w.contract.Rounds(opts, coin)
+		if err != nil {
+			return fmt.Errorf("rounds(%s): %w", coin, err)
+		}
+		price, err := w.contract.CurrentPrices(opts, coin)
+		if err != nil {
+			return fmt.Errorf("currentPrices(%s): %w", coin, err)
+		}
+
+		view := &RoundView{RoundID: round.Id, Price: price}
+		if round.LastUpdatedAt.Sign() > 0 {
+			view.LastUpdatedAt = time.Unix(round.LastUpdatedAt.Int64(), 0)
+			w.metrics.SetOnChainUpdatedAt(coin, view.LastUpdatedAt)
+		}
+		if round.Id.Sign() > 0 {
+			view.FinalizedRound = new(big.Int).Sub(round.Id, big.NewInt(1))
+		}
+
+		w.mu.Lock()
+		w.rounds[coin] = view
+		w.mu.Unlock()
+	}
+
+	w.mu.Lock()
+	w.lastBlock = head
+	w.mu.Unlock()
+	return nil
+}
+
+// Subscribe to PriceUpdated and process events until the subscription fails
+// Events missed while disconnected are caught up with FilterLogs first
+// subscribed tells whether the subscription was up before failing
+func (w *Watcher) subscribe(ctx context.Context) (subscribed bool, err error) {
+	events := make(chan *OraclePriceUpdated)
+	sub, err := w.contract.WatchPriceUpdated(&bind.WatchOpts{Context: ctx}, events, w.coinNames())
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+	w.logger.Info("Watching PriceUpdated events over websocket")
+
+	if err := w.catchUp(ctx); err != nil {
+		w.logger.Warn("Failed to catch up on PriceUpdated events", "error", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case err := <-sub.Err():
+			return true, err
+		case event := <-events:
+			w.handle(ctx, event)
+		}
+	}
+}
+
+// Poll FilterLogs for new events until ctx is done
+func (w *Watcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.catchUp(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Warn("Failed to poll PriceUpdated events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Process the events emitted since the last processed block
+// That block is scanned again: the connection may have dropped between two of
+// its events, and the ones already handled are skipped by their round ID
+func (w *Watcher) catchUp(ctx context.Context) error {
+	head, err := w.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	from := w.lastBlock
+	w.mu.Unlock()
+	if from > head {
+		return nil
+	}
+
+	iter, err := w.contract.FilterPriceUpdated(&bind.FilterOpts{Start: from, End: &head, Context: ctx}, w.coinNames())
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	for iter.Next() {
+		w.handle(ctx, iter.Event)
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	w.lastBlock = max(w.lastBlock, head)
+	w.mu.Unlock()
+	return nil
+}
+
+// Update the local view with a finalized round and report it when we did not take part
+func (w *Watcher) handle(ctx context.Context, event *OraclePriceUpdated) {
+	coin, ok := w.coins[event.Coin]
+	if !ok || event.Raw.Removed {
+		return
+	}
+
+	w.mu.Lock()
+	view, ok := w.rounds[coin]
+	if !ok {
+		view = &RoundView{}
+		w.rounds[coin] = view
+	}
+	if view.FinalizedRound != nil && event.RoundId.Cmp(view.FinalizedRound) <= 0 {
+		// Already seen, replayed by a catch-up after a resubscription
+		w.mu.Unlock()
+		return
+	}
+	view.FinalizedRound = event.RoundId
+	view.RoundID = new(big.Int).Add(event.RoundId, big.NewInt(1))
+	view.Price = event.Price
+	view.Block = event.Raw.BlockNumber
+	w.lastBlock = max(w.lastBlock, event.Raw.BlockNumber)
+	w.mu.Unlock()
+
+	// lastUpdatedAt is set to the timestamp of the finalizing block
+	if header, err := w.client.HeaderByNumber(ctx, new(big.Int).SetUint64(event.Raw.BlockNumber)); err == nil {
+		updatedAt := time.Unix(int64(header.Time), 0)
+		w.mu.Lock()
+		view.LastUpdatedAt = updatedAt
+		w.mu.Unlock()
+		w.metrics.SetOnChainUpdatedAt(coin, updatedAt)
+	}
+
+	logger := w.logger.With("coin", coin, "round_id", event.RoundId.String())
+	logger.Info("Round finalized", "price", w.feeds[coin].Format(event.Price),
+		"block", event.Raw.BlockNumber, "tx_hash", event.Raw.TxHash.Hex())
+
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(event.Raw.BlockNumber)}
+	submitted, err := w.contract.HasSubmitted(opts, coin, event.RoundId, w.address)
+	if err != nil {
+		logger.Warn("Failed to check our submission", "error", err)
+		return
+	}
+	w.store.Record(w.logger, HistoryRecord{
+		Kind:      RecordRound,
+		Coin:      coin,
+		Time:      view.LastUpdatedAt,
+		Price:     w.feeds[coin].Format(event.Price),
+		RoundID:   event.RoundId.String(),
+		TxHash:    event.Raw.TxHash.Hex(),
+		Block:     event.Raw.BlockNumber,
+		Submitted: &submitted,
+	})
+	if !submitted {
+		w.metrics.roundsMissed.WithLabelValues(coin).Inc()
+		logger.Warn("Round finalized without our submission")
+	}
+}